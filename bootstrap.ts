@@ -14,6 +14,12 @@ type World = {
   gen: number;
   continuation?: (args: FourArgs) => void;
 };
+/** RegisteredIntent is an intent registered in Go, see RegisterIntent */
+type RegisteredIntent = {
+  code: IntentType;
+  event: string;
+  registers: string[];
+};
 
 /**
  * Renderer is the wrapper around the Go WASM code.
@@ -23,9 +29,10 @@ type World = {
  * # Concurrency model
  *
  * The view is protected by an optimistic locking scheme:
- * an event is only processed if from the current gen.
+ * an event carries the gen of the view which raised it.
  *
- * This guaranties that the tree structure in the view is the same than the one which raises the event.
+ * The engine only processes events from the current gen, which guaranties that the tree structure in the view is the same than the one which raises the event.
+ * Events from an older gen are retargeted to the current one when the engine is configured to (see RetargetStale), or dropped.
  * Other properties (mouse position, viewport, scroll, …) are not tracked, and should be captured in the handler if desired.
  *
  * Note that changes to the gen can happen from Go, without JS calls:
@@ -85,21 +92,33 @@ class Renderer extends HTMLElement {
   mxevent: boolean = false; // protects event loop task
   modifiers: modifiers = [false, false, false];
   mouse: [x: number, y: number];
+  // the first view was rendered on the server, see RenderHTML
+  hydrating = false;
 
   activeModule: Promise<void>;
   _tripModule: () => void;
   running = true;
+  // intents registered in Go, by name
+  intents: { [name: string]: IntentType } = {};
+  intentHandlers: [event: string, handler: (e: Event) => void][] = [];
+  // parts of the view with event handlers attached
+  listening: EventTarget[] = [];
+  // mount points of the host page holding portals
+  hostPortals: string[] = [];
 
   // this is installed during the module instanciation
   // see main_js.go
   updateGo: {
     (event: IntentType, entity: number, world: World): void;
     (event: IntentType.Seppuku): void;
+    (event: IntentType.NoIntent): RegisteredIntent[];
   };
 
   constructor() {
     super();
-    const shadow = this.attachShadow({ mode: "open" });
+    // the server can render the first view in a declarative shadow root
+    const shadow = this.shadowRoot ?? this.attachShadow({ mode: "open" });
+    this.hydrating = shadow.hasChildNodes();
 
     // adopted CSS styles
     if (!Renderer.cachedStyleSheet) {
@@ -134,17 +153,7 @@ class Renderer extends HTMLElement {
      * @see main_js.go
      */
 
-    this.shadowRoot.addEventListener("click", this);
-    this.shadowRoot.addEventListener("dblclick", this);
-    this.shadowRoot.addEventListener("contextmenu", this);
-    // this.shadowRoot.addEventListener("change", this);
-    this.shadowRoot.addEventListener("focusout", this);
-    this.shadowRoot.addEventListener("dragover", this);
-    this.shadowRoot.addEventListener("dragenter", this);
-    this.shadowRoot.addEventListener("drop", this);
-    this.shadowRoot.addEventListener("dragend", this);
-    this.shadowRoot.addEventListener("dragstart", this);
-    this.shadowRoot.addEventListener("keyup", this);
+    this.listen(this.shadowRoot);
     // document-tied events, must be removed in disconnectedCallback
     document.addEventListener("mousemove", this);
     document.addEventListener("wheel", this, { passive: false });
@@ -163,6 +172,11 @@ class Renderer extends HTMLElement {
       args = ["-datum", this.getAttribute("initial-datum"), ...args];
     }
 
+    // learn the intents registered in Go once it runs
+    this.activeModule.then(() =>
+      this.listenIntents(this.updateGo(IntentType.NoIntent)),
+    );
+
     this.go = new Go(args, env, this as any);
     await this.module
       .then((module) => WebAssembly.instantiate(module, this.go.importObject))
@@ -183,6 +197,60 @@ class Renderer extends HTMLElement {
     document.removeEventListener("mousemove", this);
   }
 
+  // listen attaches the event handlers to a part of the view: the shadow root, or a mount point of the host page.
+  listen(target: EventTarget) {
+    if (this.listening.includes(target)) {
+      return;
+    }
+    this.listening.push(target);
+
+    target.addEventListener("click", this);
+    target.addEventListener("dblclick", this);
+    target.addEventListener("contextmenu", this);
+    // target.addEventListener("change", this);
+    target.addEventListener("focusout", this);
+    target.addEventListener("dragover", this);
+    target.addEventListener("dragenter", this);
+    target.addEventListener("drop", this);
+    target.addEventListener("dragend", this);
+    target.addEventListener("dragstart", this);
+    target.addEventListener("keyup", this);
+    for (const [event, handler] of this.intentHandlers) {
+      target.addEventListener(event, handler);
+    }
+  }
+
+  // listenIntents raises registered intents when their DOM event fires.
+  // The registers are read from the event, following the property paths given in Go.
+  listenIntents(registered: RegisteredIntent[]) {
+    for (const { code, event, registers } of registered) {
+      if (this.intents[event] !== undefined) {
+        continue; // already listening, e.g. after a reconnection
+      }
+      this.intents[event] = code;
+      const handler = (e: Event) => {
+        const regs = ["", "", "", ""] as registers;
+        registers.forEach((path, i) => {
+          regs[i] = path.split(".").reduce((v: any, k) => v?.[k], e) ?? "";
+        });
+        this.passEvent(code, this.locateEntity(e.target), { registers: regs });
+      };
+      this.intentHandlers.push([event, handler]);
+      for (const target of this.listening) {
+        target.addEventListener(event, handler);
+      }
+    }
+  }
+
+  // findEntity returns the element of the entity, in the shadow root or in a mount point of the host page (see Portal).
+  findEntity(ntt: string): Element | null {
+    let n = this.shadowRoot.getElementById(ntt);
+    for (const name of this.hostPortals) {
+      n ??= portalTarget(name)?.querySelector(`[id="${ntt}"]`) ?? null;
+    }
+    return n;
+  }
+
   // locateEntity provides an extension point to inject custom entity locator code.
   // The default option is to use the DOM tree, but other options are possible (e.g. based on geometric distance).
   locateEntity = ancestorOf;
@@ -277,7 +345,7 @@ class Renderer extends HTMLElement {
         }, DEBOUNCE_TIMEOUT);
       }
 
-      const entity = (event.target as HTMLElement | SVGElement).closest("[id]");
+      const entity = entityOf(event.target as HTMLElement | SVGElement);
       // silence drops over empty zones (but should we not fold this into accepting the drop in the first place?)
       if (!entity) return;
 
@@ -318,7 +386,7 @@ class Renderer extends HTMLElement {
         return;
       }
 
-      const entity = elem.closest("[id]")!;
+      const entity = entityOf(elem)!;
 
       const [data,effect,image, _t] = await new Promise<FourArgs>((continuation) =>
           this.passEvent(IntentType.DragStart, entity, { continuation }),
@@ -469,7 +537,7 @@ class Renderer extends HTMLElement {
     }
 
     const gen = this.gen;
-    if (!this.shadowRoot.getElementById(entity.toString())) {
+    if (!this.findEntity(entity.toString())) {
       // event was fired from a node has been deleted since
       // this is possible if the event is fired between the call to updateGo and the time the new rendering is done
       return;
@@ -489,15 +557,15 @@ class Renderer extends HTMLElement {
           entity: entity,
           world,
         };
-        const jsWorld = await this.buildJSWorld(evt.world, entityNode);
+        const jsWorld = await this.buildJSWorld(
+          { gen: evt.gen, ...evt.world },
+          entityNode,
+        );
         await this.activeModule; // Go is ready to accept args
 
         // all must be sync below this point
-        if (evt.gen === this.gen) {
-          this.updateGo(eventType, entity, jsWorld);
-        } else {
-          // drop this event
-        }
+        // stale events are passed too, the engine decides to retarget or drop them
+        this.updateGo(eventType, entity, jsWorld);
       } finally {
         this.mxevent = false;
       }
@@ -508,8 +576,121 @@ class Renderer extends HTMLElement {
     queueMicrotask(sched);
   };
 
+  /**
+   * hydrate runs the first program on the elements rendered by the server, instead of creating them.
+   * The elements must be the same than the ones the program would create: an error is thrown otherwise.
+   * Attributes, styles and properties are assigned again, since they are cheap and idempotent.
+   * Portals are not rendered by the server: false is returned when reaching one, and the view must be rebuilt.
+   */
+  hydrate = (parr: Uint8Array): boolean => {
+    const program = new DataView(parr.buffer);
+    const decoder = new TextDecoder("utf-8");
+
+    let ip = 0;
+    const loadString = () => {
+      const len = program.getUint16(ip);
+      ip += 2;
+      const txt = decoder.decode(new DataView(program.buffer, ip, len));
+      ip += len;
+      return txt;
+    };
+    // formatting whitespace and comments of the server page are not part of the view
+    const skipBlank = (n: ChildNode | null) => {
+      while (
+        n instanceof Comment ||
+        (n instanceof Text && n.data.trim() === "")
+      ) {
+        n = n.nextSibling;
+      }
+      return n;
+    };
+
+    let anchor: Element | ShadowRoot = this.shadowRoot;
+    let next = skipBlank(anchor.firstChild);
+    while (ip < program.byteLength) {
+      const instr = program.getUint8(ip);
+      ip += 1;
+      switch (instr) {
+        case OpType.OpTerm:
+          if (next) {
+            throw new Error("extra elements at the end of the view");
+          }
+          this.gen++;
+          return true;
+        case OpType.OpCreateElement:
+          {
+            const tag = loadString();
+            if (!(next instanceof Element) || next.localName.toLowerCase() !== tag) {
+              throw new Error(`expected element ${tag}, found ${next?.nodeName}`);
+            }
+            anchor = next;
+            next = skipBlank(anchor.firstChild);
+          }
+          break;
+        case OpType.OpSetID:
+          {
+            const ntt = loadString();
+            if ((anchor as Element).id !== ntt) {
+              throw new Error(`expected entity ${ntt}, found ${(anchor as Element).id}`);
+            }
+          }
+          break;
+        case OpType.OpSetClass:
+          (anchor as Element).setAttribute("class", loadString());
+          break;
+        case OpType.OpSetAttr:
+          (anchor as Element).setAttribute(loadString(), loadString());
+          break;
+        case OpType.OpSetStyle:
+          setStyle(anchor as HTMLElement, loadString(), loadString());
+          break;
+        case OpType.OpSetProp:
+          {
+            const name = loadString();
+            anchor[name] = JSON.parse(loadString());
+          }
+          break;
+        case OpType.OpAddText:
+          {
+            // text is always the first child of the element, even if blank
+            const txt = loadString();
+            const t = anchor.firstChild;
+            if (!(t instanceof Text)) {
+              throw new Error(`expected text "${txt}", found ${t?.nodeName}`);
+            }
+            t.data = txt;
+            next = skipBlank(t.nextSibling);
+          }
+          break;
+        case OpType.OpNext:
+          if (next) {
+            throw new Error(`unexpected ${next.nodeName} in ${anchor.nodeName}`);
+          }
+          next = skipBlank(anchor.nextSibling);
+          anchor = anchor.parentNode as Element | ShadowRoot;
+          break;
+        case OpType.OpPortal:
+          return false;
+        default:
+          throw new Error(`instruction ${OpType[instr]} cannot attach to the view`);
+      }
+    }
+    throw new Error("invalid XAS code, no term instructions");
+  };
+
   redraw = (parr: Uint8Array) => {
     // Use fat arrow syntax to make sure "this" is bound to instance
+    if (this.hydrating) {
+      this.hydrating = false;
+      try {
+        if (this.hydrate(parr)) {
+          return;
+        }
+      } catch (e) {
+        console.warn("cannot attach to the server-rendered view, rebuilding it", e);
+      }
+    }
+
     const program = new DataView(parr.buffer);
 
     // offsets
@@ -520,8 +701,20 @@ class Renderer extends HTMLElement {
 
     let ip = 0;
     const ndoc = new DocumentFragment();
+    // root is where the program builds: a new fragment, or the live shadow root when patching
+    let root: DocumentFragment | ShadowRoot = ndoc;
     let anchor: DocumentFragment | Element | any = ndoc; // covers initialization weirdness
     let next = anchor.firstChild;
+    // portals built by the program, and the cursor to restore when leaving them
+    const portals = new Map<string, DocumentFragment | Element>();
+    const outside: [anchor: any, next: any, portal: any][] = [];
+    const byId = (ntt: string) => {
+      let n = root.getElementById(ntt);
+      for (const p of portals.values()) {
+        n ??= p.querySelector(`[id="${ntt}"]`);
+      }
+      return n ?? this.findEntity(ntt);
+    };
     const loadString = () => {
       const len = program.getUint16(ip);
       ip += str_size;
@@ -535,16 +728,147 @@ class Renderer extends HTMLElement {
       ip += instr_size;
       switch (instr) {
         case OpType.OpTerm:
-          for (
-            let p = this.shadowRoot.firstChild;
-            p !== null;
-            p = p.nextSibling
-          ) {
-            p.remove();
+          if (root === ndoc) {
+            // layers are on top of the view, host mount points are replaced
+            const hosted: string[] = [];
+            for (const [name, p] of portals) {
+              const target = portalTarget(name);
+              if (target) {
+                target.replaceChildren(p);
+                target.setAttribute("data-portal", name);
+                this.listen(target);
+                hosted.push(name);
+              } else if (p instanceof Element) {
+                ndoc.append(p);
+              } else {
+                console.warn(`no element ${name} in the page, portal not rendered`);
+              }
+            }
+            for (const name of this.hostPortals) {
+              if (!portals.has(name)) {
+                portalTarget(name)?.replaceChildren();
+                portalTarget(name)?.removeAttribute("data-portal");
+              }
+            }
+            this.hostPortals = hosted;
+            this.shadowRoot.replaceChildren(ndoc);
           }
-          this.shadowRoot.appendChild(ndoc);
           this.gen++;
           return;
+        case OpType.OpPortal:
+          {
+            // the children are rendered in the mount point, until the matching OpNext
+            const name = loadString();
+            let p = portals.get(name);
+            if (root !== ndoc) {
+              // a target appearing is created, see the removal of empty targets in OpNext
+              if (name.startsWith("#")) {
+                p = portalTarget(name) ?? undefined;
+                if (!p) {
+                  console.warn(`no element ${name} in the page, portal not rendered`);
+                  p = new DocumentFragment();
+                } else if (!this.hostPortals.includes(name)) {
+                  p.replaceChildren();
+                  p.setAttribute("data-portal", name);
+                  this.listen(p);
+                  this.hostPortals.push(name);
+                }
+              } else {
+                p = Array.from(this.shadowRoot.children).find(
+                  (c) => c.getAttribute("data-portal") === name,
+                );
+                if (!p) {
+                  p = document.createElement("div");
+                  p.setAttribute("data-portal", name);
+                  this.shadowRoot.append(p);
+                }
+              }
+            } else if (!p) {
+              p = name.startsWith("#") ? new DocumentFragment() : document.createElement("div");
+              if (p instanceof Element) {
+                p.setAttribute("data-portal", name);
+              }
+              portals.set(name, p);
+            }
+            outside.push([anchor, next, p]);
+            anchor = p;
+            next = root === ndoc ? null : p.firstElementChild;
+          }
+          break;
+        case OpType.OpPatch:
+          // the rest of the program patches the current tree in place
+          root = this.shadowRoot;
+          anchor = this.shadowRoot;
+          next = anchor.firstElementChild;
+          break;
+        case OpType.OpEnter:
+          anchor = next;
+          next = anchor.firstElementChild;
+          break;
+        case OpType.OpSkip:
+          {
+            let count = parseInt(loadString());
+            while (count-- > 0) {
+              next = next.nextElementSibling;
+            }
+          }
+          break;
+        case OpType.OpMove:
+          {
+            const ntt = loadString();
+            const n = byId(ntt);
+            if (!n) {
+              throw new Error(`Couldn't move node of id '${ntt}', not found`);
+            }
+            anchor.insertBefore(n, next);
+            next = n;
+          }
+          break;
+        case OpType.OpRemove:
+          {
+            const n = next;
+            next = n.nextElementSibling;
+            n.remove();
+          }
+          break;
+        case OpType.OpRemoveAttr:
+          anchor.removeAttribute(loadString());
+          break;
+        case OpType.OpSetStyle:
+          {
+            // an empty value removes the declaration, see SetStyle
+            const name = loadString();
+            const value = loadString();
+            if (value === "") {
+              anchor.style.removeProperty(name);
+            } else {
+              setStyle(anchor, name, value);
+            }
+          }
+          break;
+        case OpType.OpSetProp:
+          {
+            // properties hold the live state of form elements, unlike attributes
+            const name = loadString();
+            anchor[name] = JSON.parse(loadString());
+          }
+          break;
+        case OpType.OpSetText:
+          {
+            // text is always the first child of the element, see OpAddText
+            const txt = loadString();
+            const t = anchor.firstChild;
+            if (t instanceof Text) {
+              if (txt === "") {
+                t.remove();
+              } else {
+                t.data = txt;
+              }
+            } else if (txt !== "") {
+              anchor.insertBefore(document.createTextNode(txt), t);
+            }
+          }
+          break;
         case OpType.OpCreateElement:
           {
             const tag = loadString();
@@ -631,11 +955,7 @@ class Renderer extends HTMLElement {
               n = document.createElement(tag);
             }
 
-            if (next) {
-              next.replaceWith(n);
-            } else {
-              anchor.appendChild(n);
-            }
+            anchor.insertBefore(n, next);
             next = n.firstChild;
             anchor = n;
           }
@@ -643,7 +963,7 @@ class Renderer extends HTMLElement {
         case OpType.OpReuse:
           {
             const ntt = loadString();
-            const n = this.shadowRoot.getElementById(ntt);
+            const n = byId(ntt);
             if (next) {
               next.replaceWith(n);
             } else if (n) {
@@ -658,7 +978,7 @@ class Renderer extends HTMLElement {
           {
             const from = loadString();
             const to = loadString();
-            const n = ndoc.getElementById(from);
+            const n = byId(from);
             n!.id = to;
           }
           break;
@@ -693,9 +1013,21 @@ class Renderer extends HTMLElement {
           }
           break;
         case OpType.OpNext:
-          {
+          if (outside.length > 0 && anchor === outside[outside.length - 1][2]) {
+            const p = anchor;
+            [anchor, next] = outside.pop()!;
+            if (root !== ndoc && !p.firstElementChild) {
+              // the last portal of the target was closed
+              if (p.parentNode === this.shadowRoot) {
+                p.remove();
+              } else if (p instanceof Element) {
+                p.removeAttribute("data-portal");
+                this.hostPortals = this.hostPortals.filter((name) => portalTarget(name) !== p);
+              }
+            }
+          } else {
             next = anchor.nextSibling;
-            anchor = anchor.parentElement;
+            anchor = anchor.parentNode;
           }
           break;
       }
@@ -707,6 +1039,21 @@ class Renderer extends HTMLElement {
 
 customElements.define("rx-bootstrap", Renderer);
 
+// portalTarget returns the element of the host page a portal is rendered in, or null for a layer of the view.
+function portalTarget(name: string): HTMLElement | null {
+  return name.startsWith("#") ? document.getElementById(name.slice(1)) : null;
+}
+
+// setStyle sets a declaration of the inline style; setProperty expects the !important flag as a separate priority.
+function setStyle(el: HTMLElement, name: string, value: string) {
+  const m = /^([\s\S]*?)\s*!\s*important\s*$/i.exec(value);
+  if (m) {
+    el.style.setProperty(name, m[1], "important");
+  } else {
+    el.style.setProperty(name, value);
+  }
+}
+
 function getRegisters(targetNode?): registers {
   const registers = Array(4).fill("") as registers;
   if (!targetNode) {
@@ -747,8 +1094,15 @@ function ancestorOf(targetNode: EventTarget | null): HTMLElement | null {
     return targetNode
   }*/
 
-  const closest = targetNode?.closest("[id]");
-  if (!closest) {
+  return entityOf(targetNode);
+}
+
+// entityOf returns the closest element holding an entity.
+// The walk stops at the mount point of a portal: the host page around it is not part of the view.
+// Elements rendered in a portal carry an entity, so their intents reach the parents of the portal.
+function entityOf(node: Element): HTMLElement | null {
+  const closest = node.closest("[id], [data-portal]");
+  if (!closest || closest.hasAttribute("data-portal")) {
     return null;
   }
   return closest as HTMLElement;
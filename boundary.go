@@ -0,0 +1,110 @@
+package rx
+
+import (
+	"fmt"
+	"runtime/debug"
+	"slices"
+)
+
+// ErrorBoundary isolates a failing widget from the rest of the application.
+//
+// Panics raised while building Child, or in the intent handlers of its nodes, are recovered and logged.
+// The boundary then renders Fallback in place of Child, until it is [ErrorBoundary.Reset].
+// A panicking handler is rolled back like a failed action, without discarding the other actions of the turn.
+// A panic while building rolls back the whole turn: the fallback is rendered with the context left unchanged.
+//
+// Since it retains the error between turns, the boundary must outlive the rendering pass:
+//
+//	type App struct{ editor rx.ErrorBoundary }
+//
+//	func (a *App) Build(ctx rx.Context) *rx.Node {
+//		a.editor.Child = rx.WidgetFunc(Editor)
+//		return a.editor.Build(ctx)
+//	}
+type ErrorBoundary struct {
+	Child    Widget
+	Fallback func(err error) *Node // a default alert is shown if nil
+
+	err error
+}
+
+// Err returns the error caught by the boundary, if any.
+func (b *ErrorBoundary) Err() error { return b.err }
+
+// Reset clears the error, so the next rendering tries building Child again.
+func (b *ErrorBoundary) Reset(ctx Context) Context { b.err = nil; return ctx }
+
+func (b *ErrorBoundary) Build(ctx Context) (nd *Node) {
+	if b.err != nil {
+		return b.fallback()
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			b.fail(ctx, r)
+			nd = b.fallback()
+			if ctx.ng != nil {
+				ctx.ng.buildFault = true
+			}
+		}
+	}()
+
+	nd = b.Child.Build(ctx)
+	b.guard(nd, make(map[*Node]bool))
+	return nd
+}
+
+// guard wraps the handlers in the sub-tree rooted at nd, recovering from panics.
+func (b *ErrorBoundary) guard(nd *Node, seen map[*Node]bool) {
+	if nd == nil || seen[nd] {
+		return
+	}
+	seen[nd] = true
+
+	nd.hdl = slices.Clone(nd.hdl) // shared with copies of the node
+	for i, h := range nd.hdl {
+		if h == nil {
+			continue
+		}
+		nd.hdl[i] = func(ctx Context) (out Context) {
+			defer func() {
+				r := recover()
+				switch r.(type) {
+				case aborted, caught:
+					panic(r) // rolled back by the engine, or already caught by a nested boundary
+				}
+				if r != nil {
+					b.fail(ctx, r)
+					panic(caught{}) // rolled back by the engine, then rendered with the fallback
+				}
+			}()
+			return h(ctx)
+		}
+	}
+	for _, c := range nd.Children {
+		b.guard(c, seen)
+	}
+}
+
+func (b *ErrorBoundary) fail(ctx Context, r any) {
+	if err, ok := r.(error); ok {
+		b.err = fmt.Errorf("rx: recovered panic: %w", err)
+	} else {
+		b.err = fmt.Errorf("rx: recovered panic: %v", r)
+	}
+
+	if ctx.ng == nil {
+		return
+	}
+	ctx.ng.logger.Error("error boundary caught a panic", "error", b.err, "stack", string(debug.Stack()))
+}
+
+// caught is the panic value raised by a handler, once its [ErrorBoundary] recovered
+type caught struct{}
+
+func (b *ErrorBoundary) fallback() *Node {
+	if b.Fallback != nil {
+		return b.Fallback(b.err)
+	}
+	return Get(`<div role="alert">`).SetText(b.err.Error())
+}
@@ -0,0 +1,93 @@
+package rx
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestErrorBoundary(t *testing.T) {
+	type broken bool
+	errClick := errors.New("click failed")
+
+	b := &ErrorBoundary{Child: WidgetFunc(func(ctx Context) *Node {
+		if ValueOf[broken](ctx) {
+			panic("build failed")
+		}
+		return Get(`<button>`).OnIntent(Click, func(Context) Context { panic(errClick) })
+	})}
+
+	ng := New(WidgetFunc(func(ctx Context) *Node { return Get(`<main>`).AddChildren(b.Build(ctx)) }))
+	defer ng.Close()
+	ng.mx.Lock()
+	defer ng.mx.Unlock()
+
+	ng.turncrank(DoNothing)
+	if b.Err() != nil {
+		t.Fatalf("unexpected error %v", b.Err())
+	}
+
+	xas := ng.turncrank(ng.batch(ng.intent(CallFrame{IntentType: Click, Entity: ng.et.g1[0].ntt, Gen: ng.gen})))
+	if !errors.Is(b.Err(), errClick) {
+		t.Errorf("handler panic: want %v, got %v", errClick, b.Err())
+	}
+	if !strings.Contains(string(xas), "click failed") {
+		t.Errorf("fallback not rendered after handler panic")
+	}
+
+	xas = ng.turncrank(ng.batch(Chain(b.Reset, LoadContext(broken(true)))))
+	if b.Err() == nil || !strings.Contains(b.Err().Error(), "build failed") {
+		t.Errorf("build panic: got %v", b.Err())
+	}
+	if !strings.Contains(string(xas), "build failed") {
+		t.Errorf("fallback not rendered after build panic")
+	}
+	if ValueOf[broken](Context{vx: ng.ctx}) {
+		t.Errorf("context of the failing turn must not be committed")
+	}
+}
+
+func TestErrorBoundaryBatch(t *testing.T) {
+	type saved bool
+
+	b := &ErrorBoundary{Child: WidgetFunc(func(ctx Context) *Node {
+		return Get(`<button>`).OnIntent(Click, func(ctx Context) Context {
+			ctx = WithValue(ctx, saved(false))
+			panic("click failed")
+		})
+	})}
+
+	ng := newEngine(WidgetFunc(func(ctx Context) *Node { return Get(`<main>`).AddChildren(b.Build(ctx)) }))
+	ng.Actions = make(chan Action, 1)
+	ng.turncrank(DoNothing)
+
+	ng.Actions <- ng.intent(CallFrame{IntentType: Click, Entity: ng.et.g1[0].ntt, Gen: ng.gen})
+	xas := ng.turncrank(ng.batch(LoadContext(saved(true))))
+	if !strings.Contains(string(xas), "click failed") {
+		t.Errorf("fallback not rendered after handler panic")
+	}
+	if !ValueOf[saved](Context{vx: ng.ctx}) {
+		t.Errorf("action batched with the failing handler must be committed")
+	}
+}
+
+func TestNestedErrorBoundary(t *testing.T) {
+	inner := &ErrorBoundary{Child: WidgetFunc(func(ctx Context) *Node {
+		return Get(`<button>`).OnIntent(Click, func(Context) Context { panic("boom") })
+	})}
+	outer := &ErrorBoundary{Child: WidgetFunc(func(ctx Context) *Node { return Get(`<section>`).AddChildren(inner.Build(ctx)) })}
+
+	ng := newEngine(outer)
+	ng.turncrank(DoNothing)
+	xas := ng.turncrank(ng.batch(ng.intent(CallFrame{IntentType: Click, Entity: ng.et.g1[0].ntt, Gen: ng.gen})))
+
+	if inner.Err() == nil || !strings.Contains(inner.Err().Error(), "boom") {
+		t.Errorf("inner boundary: got %v", inner.Err())
+	}
+	if outer.Err() != nil {
+		t.Errorf("outer boundary must not catch the panic of the inner one, got %v", outer.Err())
+	}
+	if !strings.Contains(string(xas), "boom") {
+		t.Errorf("inner fallback not rendered")
+	}
+}
@@ -2,12 +2,16 @@
 
 package rx
 
-import "io"
+import (
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+)
 
 type Type int
 
 // from syscall/js.Value
-// TODO(rdo) make this available for unit testing
 type JSValue interface {
 	Bool() bool
 	Call(m string, args ...any) JSValue
@@ -55,13 +59,89 @@ func R3(ctx Context) string { return ctx.ng.Registers[2].String() }
 func R4(ctx Context) string { return ctx.ng.Registers[3].String() }
 
 // Continuation outputs
-func S1(ctx Context, v any) { ctx.ng.Returns[0] = _panicOn(v) } //lint:ignore U1000 this is an API
-func S2(ctx Context, v any) { ctx.ng.Returns[1] = _panicOn(v) } //lint:ignore U1000 this is an API
-func S3(ctx Context, v any) { ctx.ng.Returns[2] = _panicOn(v) } //lint:ignore U1000 this is an API
-func S4(ctx Context, v any) { ctx.ng.Returns[3] = _panicOn(v) } //lint:ignore U1000 this is an API
-
-func _panicOn(any) JSValue { panic("not implemented") }
+func S1(ctx Context, v any) { ctx.ng.Returns[0] = Val(v) } //lint:ignore U1000 this is an API
+func S2(ctx Context, v any) { ctx.ng.Returns[1] = Val(v) } //lint:ignore U1000 this is an API
+func S3(ctx Context, v any) { ctx.ng.Returns[2] = Val(v) } //lint:ignore U1000 this is an API
+func S4(ctx Context, v any) { ctx.ng.Returns[3] = Val(v) } //lint:ignore U1000 this is an API
 
+// Pipe returns the reading end of the pipe wrapped in a [JSValue], see [Val].
 func Pipe() (JSValue, io.WriteCloser) {
-	panic("not implemented")
+	r, w := io.Pipe()
+	return Val(r), w
+}
+
+// Val wraps a plain Go value as a [JSValue], to be used as a register outside of the browser.
+// Only the conversions to Go types are implemented, other methods panic.
+func Val(v any) JSValue {
+	if gv, ok := v.(goValue); ok {
+		return gv
+	}
+	return goValue{v}
+}
+
+type goValue struct{ v any }
+
+func (g goValue) Bool() bool {
+	b, ok := g.v.(bool)
+	if !ok {
+		panic(fmt.Sprintf("%T is not a bool", g.v))
+	}
+	return b
+}
+
+func (g goValue) Float() float64 {
+	switch v := g.v.(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case int:
+		return float64(v)
+	default:
+		panic(fmt.Sprintf("%T is not a number", g.v))
+	}
 }
+
+func (g goValue) Int() int {
+	switch v := g.v.(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	default:
+		panic(fmt.Sprintf("%T is not a number", g.v))
+	}
+}
+
+func (g goValue) String() string {
+	if s, ok := g.v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(g.v)
+}
+
+func (g goValue) Equal(w JSValue) bool {
+	o, ok := w.(goValue)
+	return ok && g.v == o.v
+}
+
+func (g goValue) IsNaN() bool {
+	f, ok := g.v.(float64)
+	return ok && math.IsNaN(f)
+}
+
+func (g goValue) IsNull() bool      { return g.v == nil }
+func (g goValue) IsUndefined() bool { return false }
+func (g goValue) Truthy() bool      { return g.v != nil && !reflect.ValueOf(g.v).IsZero() }
+func (g goValue) Type() Type        { return 0 }
+
+func (g goValue) Call(m string, args ...any) JSValue { panic("not implemented") }
+func (g goValue) Delete(p string)                    { panic("not implemented") }
+func (g goValue) Get(p string) JSValue               { panic("not implemented") }
+func (g goValue) Index(i int) JSValue                { panic("not implemented") }
+func (g goValue) InstanceOf(t JSValue) bool          { panic("not implemented") }
+func (g goValue) Invoke(args ...any) JSValue         { panic("not implemented") }
+func (g goValue) Length() int                        { panic("not implemented") }
+func (g goValue) New(args ...any) JSValue            { panic("not implemented") }
+func (g goValue) Set(p string, x any)                { panic("not implemented") }
+func (g goValue) SetIndex(i int, x any)              { panic("not implemented") }
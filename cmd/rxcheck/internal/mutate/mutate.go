@@ -2,6 +2,7 @@ package mutate
 
 import (
 	"go/ast"
+	"go/types"
 
 	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/analysis/passes/inspect"
@@ -33,6 +34,10 @@ func run_callptr(pass *analysis.Pass) (any, error) {
 		}
 
 		for _, arg := range call.Args {
+			if isAction(pass.TypesInfo.TypeOf(arg)) {
+				continue // actions are applied as is
+			}
+
 			flit, ok := arg.(*ast.FuncLit)
 			if !ok {
 				pass.Reportf(arg.Pos(), "mutate function must be single pointers")
@@ -52,3 +57,12 @@ func run_callptr(pass *analysis.Pass) (any, error) {
 
 	return nil, nil
 }
+
+func isAction(t types.Type) bool {
+	n, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := n.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "github.com/TroutSoftware/rx" && obj.Name() == "Action"
+}
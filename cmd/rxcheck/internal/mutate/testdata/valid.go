@@ -5,3 +5,4 @@ import (
 )
 
 var _ = rx.Mutate(func(x *int) { *x = 2 })
+var _ = rx.Mutate(rx.Update(func(x *int) { *x = 2 }))
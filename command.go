@@ -0,0 +1,77 @@
+package rx
+
+import "context"
+
+// Go runs fn on a new goroutine, outside of the rendering loop, and posts the action it returns back to the engine.
+// This is how actions should perform I/O, e.g. fetching data from the network:
+//
+//	func refresh(ctx rx.Context) rx.Context {
+//		rx.Go(ctx, func(cx context.Context) rx.Action {
+//			rows, err := fetchRows(cx)
+//			return rx.LoadContext(rows, err)
+//		})
+//		return ctx
+//	}
+//
+// The context given to fn is cancelled when the engine shuts down, in which case the action is dropped.
+// A nil action is not posted.
+func Go(ctx Context, fn func(context.Context) Action) { GoLatest(ctx, nil, fn) }
+
+// GoLatest is like [Go], but the command supersedes any command started earlier with the same key.
+// Superseded commands are cancelled, and their action is dropped, even if already posted.
+// This is useful when only the latest request matters, e.g. search as you type.
+func GoLatest(ctx Context, key any, fn func(context.Context) Action) {
+	ng := ctx.ng
+	cx, cancel := context.WithCancel(ng.life)
+	cmd := &command{cancel: cancel}
+
+	if key != nil {
+		ng.cmx.Lock()
+		if prev := ng.cmds[key]; prev != nil {
+			prev.cancel()
+		}
+		if ng.cmds == nil {
+			ng.cmds = make(map[any]*command)
+		}
+		ng.cmds[key] = cmd
+		ng.cmx.Unlock()
+	}
+
+	go func() {
+		defer cancel()
+
+		act := fn(cx)
+		if act == nil || cx.Err() != nil {
+			ng.release(key, cmd)
+			return
+		}
+
+		post := func(ctx Context) Context {
+			if !ng.release(key, cmd) {
+				return noAction
+			}
+			return act(ctx)
+		}
+		select {
+		case ng.Actions <- post:
+		case <-ng.life.Done():
+		}
+	}()
+}
+
+type command struct{ cancel context.CancelFunc }
+
+// release forgets the command, returning false if it was superseded.
+func (ng *Engine) release(key any, cmd *command) bool {
+	if key == nil {
+		return true
+	}
+
+	ng.cmx.Lock()
+	defer ng.cmx.Unlock()
+	if ng.cmds[key] != cmd {
+		return false
+	}
+	delete(ng.cmds, key)
+	return true
+}
@@ -0,0 +1,48 @@
+package rx
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCommands(t *testing.T) {
+	type result string
+
+	ng := New(WidgetFunc(func(ctx Context) *Node { return Get(`<div>`) }))
+	ctx := Context{ng: ng}
+
+	cancelled := make(chan struct{})
+	GoLatest(ctx, "search", func(cx context.Context) Action {
+		<-cx.Done()
+		close(cancelled)
+		return LoadContext(result("slow"))
+	})
+	GoLatest(ctx, "search", func(cx context.Context) Action {
+		return LoadContext(result("fast"))
+	})
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("superseded command not cancelled")
+	}
+
+	ng.ReleaseXAS(<-ng.XAS)
+	if got := ValueOf[result](Context{vx: ng.ctx}); got != "fast" {
+		t.Errorf("want latest command result, got %q", got)
+	}
+
+	stopped := make(chan struct{})
+	Go(ctx, func(cx context.Context) Action {
+		<-cx.Done()
+		close(stopped)
+		return nil
+	})
+	ng.Close()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("command not cancelled on shutdown")
+	}
+}
@@ -2,14 +2,19 @@ package rx
 
 import (
 	"fmt"
+	"maps"
 	"reflect"
+	"slices"
 	"strings"
-	"sync"
 )
 
 // Context carries a set of values down the rendering tree.
 // This is used by UI elements to pass values between rendering passes.
 // A build context can safely be shared between goroutines, and so can the children.
+//
+// Contexts are immutable: setting a value returns a new context, and the original one is unchanged.
+// The context returned by an action is committed by the engine, and becomes the state of the application.
+// Values set while building widgets are scoped to the widgets receiving the new context, and are discarded at the end of the rendering.
 // The zero build context is valid, but only marginally useful, as it cannot be used to link nodes to widgets.
 // Do not confuse it with the standard library’s [context.Context], which does allow to pass values, but also a lot more.
 //
@@ -25,11 +30,9 @@ type Context struct {
 func (c Context) Dump() string {
 	var buf strings.Builder
 	fmt.Fprint(&buf, "[")
-	c.vx.ml.Lock()
-	for typ, val := range c.vx.kv {
+	for typ, val := range c.vx.all() {
 		fmt.Fprintf(&buf, "\n\t%s=%v", typ, val)
 	}
-	c.vx.ml.Unlock()
 	fmt.Fprint(&buf, "\n]")
 	return buf.String()
 }
@@ -63,12 +66,15 @@ func Keep[T any](ctx Context, nd *Node) {
 }
 
 // Reuse returns a node of type kept during the previous rendering cycle.
-// If no node is kept at T (or was kept more than one rendering cycle ago), nil is returned.
+// If no node is kept at T (or was kept more than one rendering cycle ago, or was not rendered), nil is returned.
 func Reuse[T any](ctx Context) *Node {
 	typ := reflect.TypeFor[T]()
 
 	for p := ctx.ng.k1; p != nil; p = p.next {
 		if p.key == typ {
+			if ctx.ng.et.locate(p.val) == -1 {
+				return nil // the element must be built again
+			}
 			n := getNode("reuse")
 			n.old = p.val
 			n.GiveKey(ctx)
@@ -82,55 +88,67 @@ func Reuse[T any](ctx Context) *Node {
 // DoNothing returns the original context – the data is not updated
 func DoNothing(ctx Context) Context { return ctx }
 
-// vctx is a lock-protected map.
-type vctx struct {
-	ml sync.Mutex
-	kv map[reflect.Type]any
+// WithValue returns a new context holding value, which should be passed down the building stack.
+// Existing values of the same key are hidden, but not overwritten:
+// widgets built with the original context (e.g. siblings) still see the previous value.
+//
+// # Concurrency note
+//
+// Contexts are immutable, and can be freely shared between goroutines.
+// Two goroutines deriving a value from the same context each get their own context; neither sees the value of the other.
+//
+// If a [Key] is given, the value is stored at the key instead of its type.
+func WithValue[T any](ctx Context, value T, at ...*Key[T]) Context {
+	if len(at) == 0 {
+		return WithValues(ctx, value)
+	}
+	return store(ctx, value, at)
 }
 
-// WithValue adds a new value in the context, which should be passed down the building stack.
-// Existing values of the same key are hidden, but not overwritten.
+// Key identifies a value of type T in the context, independently of other values of the same type:
 //
-// # Concurrency note
+//	var SidebarOpen = rx.NewKey[bool]("sidebar")
+//
+//	ctx = rx.WithValue(ctx, true, SidebarOpen)
+//	if rx.ValueOf(ctx, SidebarOpen) { … }
 //
-// The happens-after relationship could look a bit counter-intuitive; without further synchronization, two goroutines G1 and G2 would be able to write their value, but read the value from the other goroutine.
-// We believe this is an acceptable tradeoff as this is not a common case, and adding synchronization (e.g. through channels) is both trivial, and clearer anyway.
-// We do ensure that the data structure remains valid from concurrent access.
-func WithValue[T any](ctx Context, value T) Context { return WithValues(ctx, value) }
+// Functions accepting a key use it in place of the type; only the first key given is used.
+type Key[T any] struct{ name string }
 
-// WithValues set all values in context.
-// If a value is an action, it is executed in place (use a dedicated go routine to delay execution).
-func WithValues(ctx Context, values ...any) Context {
-	if ctx.vx == nil {
-		ctx.vx = &vctx{kv: make(map[reflect.Type]any)}
+// NewKey returns a new key, distinct from all others (even of the same name).
+// The name is only used to describe the value, e.g. in [Context.Dump].
+func NewKey[T any](name string) *Key[T] { return &Key[T]{name: name} }
+
+func (k *Key[T]) String() string { return k.name }
+
+// keyOf returns the key of values of type T in the context
+func keyOf[T any](at []*Key[T]) any {
+	if len(at) > 0 {
+		return at[0]
 	}
+	return reflect.TypeFor[T]()
+}
 
-	ctx.vx.ml.Lock()
+// WithValues returns a new context holding all values.
+// If a value is an action, it is executed in place (use a dedicated go routine to delay execution).
+func WithValues(ctx Context, values ...any) Context {
 	for _, v := range values {
 		if act, ok := v.(Action); ok {
-			ctx.vx.ml.Unlock()
 			ctx = act(ctx)
-			ctx.vx.ml.Lock()
 		} else {
-			ctx.vx.kv[reflect.TypeOf(v)] = v
+			ctx.vx = ctx.vx.with(reflect.TypeOf(v), v)
 		}
 	}
-	ctx.vx.ml.Unlock()
 
 	return ctx
 }
 
-// ValueOf returns a value of type T at key.
+// ValueOf returns a value of type T, or the value at the [Key] if given.
 // If the type of T is invalid, the function panics.
-func ValueOf[T any](ctx Context) T {
+func ValueOf[T any](ctx Context, at ...*Key[T]) T {
 	var z T
 
-	vx := ctx.vx
-	if vx == nil {
-		return z
-	}
-
-	val, ok := vx.kv[reflect.TypeFor[T]()]
+	val, ok := ctx.vx.get(keyOf(at))
 	if !ok {
 		return z
 	}
@@ -138,12 +156,25 @@ func ValueOf[T any](ctx Context) T {
 }
 
 // Mutate executes all mutators (which must be functions taking exactly one pointer)
-// by loading the value from the context, modifying it with the mutator and storign it.
+// by loading the value from the context, modifying a copy with the mutator and storing it in the returned context.
 // If the type is not yet registered in the context, the zero value is used instead
+// Mutators can also be actions, e.g. to update a value at a [Key]:
+//
+//	rx.Mutate(func(f *Filter) { f.Page = 0 }, rx.Update(func(open *bool) { *open = false }, SidebarOpen))
+//
 // It panics if the mutators are of the wrong type
 func Mutate(mutators ...any) Action {
 	return func(ctx Context) Context {
 		for _, m := range mutators {
+			if act, ok := m.(Action); ok {
+				ctx = act(ctx)
+				continue
+			}
+			if act, ok := m.(func(Context) Context); ok {
+				ctx = act(ctx)
+				continue
+			}
+
 			tt := reflect.TypeOf(m)
 			if tt.Kind() != reflect.Func || tt.NumIn() != 1 || tt.In(0).Kind() != reflect.Pointer || tt.NumOut() != 0 {
 				panic("mutator must be functions of one pointer argument")
@@ -151,20 +182,84 @@ func Mutate(mutators ...any) Action {
 
 			kt := tt.In(0).Elem()
 
-			ctx.vx.ml.Lock()
 			v := reflect.New(kt)
-			if vv, ok := ctx.vx.kv[kt]; ok {
+			if vv, ok := ctx.vx.get(kt); ok {
 				v.Elem().Set(reflect.ValueOf(vv))
 			}
 
 			reflect.ValueOf(m).Call([]reflect.Value{v})
-			ctx.vx.kv[kt] = v.Elem().Interface()
-			ctx.vx.ml.Unlock()
+			ctx.vx = ctx.vx.with(kt, v.Elem().Interface())
 		}
 		return ctx
 	}
 }
 
+// Set returns an action storing v in the context (at the [Key] if given).
+// This is the typed equivalent of [LoadContext] for a single value.
+func Set[T any](v T, at ...*Key[T]) Action {
+	return func(ctx Context) Context { return store(ctx, v, at) }
+}
+
+// store sets v at the static type T or key, without inspecting the value (unlike [WithValues], for interfaces or actions).
+func store[T any](ctx Context, v T, at []*Key[T]) Context {
+	ctx.vx = ctx.vx.with(keyOf(at), v)
+	return ctx
+}
+
+// Update returns an action modifying the value of type T with fn.
+// fn receives a copy of the value (or the zero value), which is then stored in the context.
+// This is the typed equivalent of [Mutate]; note the copy is shallow:
+// use [UpdateIndex], [UpdateFunc] or [UpdateKey] to modify an element of a slice or map,
+// so previous contexts (e.g. in the history) are left unchanged.
+func Update[T any](fn func(*T), at ...*Key[T]) Action {
+	return func(ctx Context) Context {
+		v := ValueOf(ctx, at...)
+		fn(&v)
+		return store(ctx, v, at)
+	}
+}
+
+// UpdateIndex returns an action modifying the i-th element of the slice of type S.
+// The slice is copied first; nothing happens if i is out of range.
+//
+//	rx.UpdateIndex[Rows](i, func(r *Row) { r.Selected = true })
+func UpdateIndex[S ~[]E, E any](i int, fn func(*E), at ...*Key[S]) Action {
+	return func(ctx Context) Context {
+		s := ValueOf(ctx, at...)
+		if i < 0 || i >= len(s) {
+			return ctx
+		}
+		s = slices.Clone(s)
+		fn(&s[i])
+		return store(ctx, s, at)
+	}
+}
+
+// UpdateFunc returns an action modifying the first element of the slice of type S for which match returns true,
+// e.g. to find a row by identifier:
+//
+//	rx.UpdateFunc[Rows](func(r Row) bool { return r.ID == id }, func(r *Row) { r.Name = name })
+func UpdateFunc[S ~[]E, E any](match func(E) bool, fn func(*E), at ...*Key[S]) Action {
+	return func(ctx Context) Context {
+		return UpdateIndex(slices.IndexFunc(ValueOf(ctx, at...), match), fn, at...)(ctx)
+	}
+}
+
+// UpdateKey returns an action modifying the element at k of the map of type M.
+// The map is copied first; fn receives the zero value if k is missing.
+func UpdateKey[M ~map[K]V, K comparable, V any](k K, fn func(*V), at ...*Key[M]) Action {
+	return func(ctx Context) Context {
+		m := maps.Clone(ValueOf(ctx, at...))
+		if m == nil {
+			m = make(M)
+		}
+		v := m[k]
+		fn(&v)
+		m[k] = v
+		return store(ctx, m, at)
+	}
+}
+
 // LoadContext loads all values in context (cf [New])
 func LoadContext(values ...any) Action {
 	return func(ctx Context) Context {
@@ -182,15 +277,15 @@ func Chain(actions ...Action) Action {
 	}
 }
 
-// Toggle sets the value in context to W if it is missing, or zero otherwise
-func Toggle[T comparable](w T) Action {
+// Toggle sets the value in context (or at the [Key] if given) to W if it is missing, or zero otherwise
+func Toggle[T comparable](w T, at ...*Key[T]) Action {
 	return func(ctx Context) Context {
-		v := ValueOf[T](ctx)
+		v := ValueOf(ctx, at...)
 		if v == w {
 			var z T
-			return WithValue(ctx, z)
+			return WithValue(ctx, z, at...)
 		} else {
-			return WithValue(ctx, w)
+			return WithValue(ctx, w, at...)
 		}
 	}
 }
@@ -1,9 +1,11 @@
 package rx
 
 import (
+	"log/slog"
 	"math/rand"
 	"net/netip"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -33,7 +35,7 @@ func BenchmarkContextValues(b *testing.B) {
 	// to reproduce this, we use the order in switch, making sure that, statistically, key5 is modified twice each time key4 is modified, and so on…
 
 	for b.Loop() {
-		ctx := Context{vx: &vctx{kv: make(map[reflect.Type]any)}}
+		var ctx Context
 
 		for range 30_000 {
 			rnd := rand.Uint32()
@@ -51,9 +53,13 @@ func BenchmarkContextValues(b *testing.B) {
 			case rnd&key1 > 0:
 				ctx = WithValue(ctx, va)
 			}
+		}
 
-			b.ReportMetric(float64(len(ctx.vx.kv)), "valuelength")
+		n := 0
+		for range ctx.vx.all() {
+			n++
 		}
+		b.ReportMetric(float64(n), "valuelength")
 	}
 }
 
@@ -66,14 +72,192 @@ func TestMutator(t *testing.T) {
 
 	ctx = WithValues(ctx, User{name: "Doe"}, Endpoint(10))
 
-	Mutate(
+	mut := Mutate(
 		func(u *User) { u.name = "Bond" },
 		func(e *Endpoint) { *e = 10 },
 		func(d *Dst) { *d = Dst(netip.MustParseAddr("192.0.2.10")) },
 	)(ctx)
 
 	want := User{name: "Bond"}
-	if gu := ValueOf[User](ctx); gu != want {
+	if gu := ValueOf[User](mut); gu != want {
 		t.Errorf("different user %s", cmp.Diff(want, gu))
 	}
+	if gu := ValueOf[User](ctx); gu.name != "Doe" {
+		t.Errorf("original context modified: %v", gu)
+	}
+}
+
+func TestScopedValues(t *testing.T) {
+	type theme string
+
+	var sibling theme
+	ng := New(WidgetFunc(func(ctx Context) *Node {
+		dark := WidgetFunc(func(ctx Context) *Node {
+			return Get(`<div>`).SetText(string(ValueOf[theme](ctx)))
+		}).Build(WithValue(ctx, theme("dark")))
+		sibling = ValueOf[theme](ctx)
+		return Get(`<main>`).AddChildren(dark)
+	}), LoadContext(theme("light")))
+	defer ng.Close()
+	ng.mx.Lock()
+	defer ng.mx.Unlock()
+
+	ng.turncrank(DoNothing)
+	if sibling != "light" {
+		t.Errorf("value leaked to sibling: %s", sibling)
+	}
+	if v := ValueOf[theme](Context{vx: ng.ctx}); v != "light" {
+		t.Errorf("render-scoped value committed: %s", v)
+	}
+}
+
+func TestPersistentMap(t *testing.T) {
+	// distinct types, to get many keys
+	keys := make([]reflect.Type, 2000)
+	for i := range keys {
+		keys[i] = reflect.ArrayOf(i, reflect.TypeFor[byte]())
+	}
+
+	var vx *vctx
+	snapshots := make([]*vctx, len(keys))
+	for i, k := range keys {
+		vx = vx.with(k, i)
+		snapshots[i] = vx
+	}
+	vx = vx.with(keys[0], -1)
+
+	for i, k := range keys {
+		want := i
+		if i == 0 {
+			want = -1
+		}
+		if v, ok := vx.get(k); !ok || v != want {
+			t.Fatalf("key %s: got %v, want %d", k, v, want)
+		}
+	}
+	if v, _ := snapshots[0].get(keys[0]); v != 0 {
+		t.Errorf("snapshot modified: got %v", v)
+	}
+	if _, ok := snapshots[10].get(keys[11]); ok {
+		t.Errorf("snapshot holds later value")
+	}
+
+	n := 0
+	for range vx.all() {
+		n++
+	}
+	if n != len(keys) {
+		t.Errorf("iterating: got %d values, want %d", n, len(keys))
+	}
+}
+
+func TestHashCollisions(t *testing.T) {
+	a, b := reflect.TypeFor[int](), reflect.TypeFor[string]()
+	// forge colliding leaves, as the hash function cannot be controlled
+	vx := (*vctx)(nil).insert(&vleaf{key: a, val: 1, hash: 42}, 0).
+		insert(&vleaf{key: b, val: 2, hash: 42}, 0).
+		insert(&vleaf{key: a, val: 3, hash: 42}, 0)
+
+	got := make(map[any]any)
+	for k, v := range vx.all() {
+		got[k] = v
+	}
+	want := map[any]any{a: 3, b: 2}
+	if !cmp.Equal(got, want) {
+		t.Errorf("collisions: %s", cmp.Diff(want, got))
+	}
+}
+
+func TestTypedUpdates(t *testing.T) {
+	type Row struct {
+		ID   int
+		Name string
+	}
+	type Rows []Row
+	type Counts map[string]int
+	type Title string
+
+	ctx := WithValues(Context{}, Rows{{1, "a"}, {2, "b"}})
+	prev := ctx
+
+	ctx = Chain(
+		Set(Title("rows")),
+		Update(func(t *Title) { *t += "!" }),
+		UpdateIndex[Rows](0, func(r *Row) { r.Name = "A" }),
+		UpdateFunc[Rows](func(r Row) bool { return r.ID == 2 }, func(r *Row) { r.Name = "B" }),
+		UpdateIndex[Rows](5, func(r *Row) { t.Error("out of range index updated") }),
+		UpdateKey[Counts]("clicks", func(n *int) { *n++ }),
+		UpdateKey[Counts]("clicks", func(n *int) { *n++ }),
+	)(ctx)
+
+	if got := ValueOf[Title](ctx); got != "rows!" {
+		t.Errorf("title: got %q", got)
+	}
+	if got, want := ValueOf[Rows](ctx), (Rows{{1, "A"}, {2, "B"}}); !cmp.Equal(got, want) {
+		t.Errorf("rows: %s", cmp.Diff(want, got))
+	}
+	if got := ValueOf[Counts](ctx)["clicks"]; got != 2 {
+		t.Errorf("clicks: got %d", got)
+	}
+	if got := ValueOf[Rows](prev)[0].Name; got != "a" {
+		t.Errorf("previous context modified: got %q", got)
+	}
+}
+
+func TestTypedKeys(t *testing.T) {
+	sidebar, details := NewKey[bool]("sidebar"), NewKey[bool]("details")
+
+	ctx := Chain(
+		LoadContext(true),
+		Toggle(true, sidebar),
+		Mutate(Update(func(open *bool) { *open = !*open }, details)),
+		Toggle(true, sidebar),
+		Set(true, details),
+		Toggle(true, details),
+	)(Context{})
+
+	if ValueOf(ctx, sidebar) || ValueOf(ctx, details) || !ValueOf[bool](ctx) {
+		t.Errorf("keys not independent: sidebar=%v details=%v bool=%v",
+			ValueOf(ctx, sidebar), ValueOf(ctx, details), ValueOf[bool](ctx))
+	}
+
+	ctx = WithValue(ctx, true, sidebar)
+	if !ValueOf(ctx, sidebar) || ValueOf(ctx, details) {
+		t.Errorf("WithValue at key: sidebar=%v details=%v", ValueOf(ctx, sidebar), ValueOf(ctx, details))
+	}
+	if dump := ctx.Dump(); !strings.Contains(dump, "sidebar=true") || !strings.Contains(dump, "details=false") {
+		t.Errorf("key names missing in dump: %s", dump)
+	}
+}
+
+func TestContextDiff(t *testing.T) {
+	type Name string
+	type Rows []int
+	filter := NewKey[string]("filter")
+
+	old := WithValues(Context{}, Name("Doe"), Rows{1, 2})
+	cur := WithValue(WithValues(old, Name("Bond"), Rows(make([]int, 100))), "open", filter)
+
+	got := make([]string, 0)
+	for _, c := range cur.Diff(old) {
+		got = append(got, c.String())
+	}
+	want := []string{
+		`filter: <nil> → open`,
+		`rx.Name: Doe → Bond`,
+		`rx.Rows: [1 2] → [0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 …`,
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("diff: %s", cmp.Diff(want, got))
+	}
+	if d := old.Diff(old); len(d) != 0 {
+		t.Errorf("no change expected, got %v", d)
+	}
+
+	var buf strings.Builder
+	ng := &Engine{logger: slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))}
+	ng.transact(Chain(LogDiffs, Set(Name("Bond"))), Context{ng: ng})
+	if !strings.Contains(buf.String(), "changes.rx.Name=") {
+		t.Errorf("changes not logged: %s", buf.String())
+	}
 }
@@ -0,0 +1,54 @@
+package rx
+
+import "sync"
+
+// Input is a type of context value, see [In].
+type Input struct{ typ any }
+
+// In declares values of type T (or at key if given) as an input of a [Derived] value.
+func In[T any](key ...*Key[T]) Input { return Input{keyOf(key)} }
+
+// Derived is a value computed from other values of the context, and cached until one of them changes.
+// It is usually declared once, at the package level:
+//
+//	var visibleRows = rx.Derive(func(ctx rx.Context) []Row {
+//		return filter(rx.ValueOf[Rows](ctx), rx.ValueOf[Query](ctx))
+//	}, rx.In[Rows](), rx.In[Query]())
+//
+// and read while building widgets:
+//
+//	rows := visibleRows.Value(ctx)
+type Derived[T any] struct {
+	fn     func(Context) T
+	inputs []Input
+
+	mx    sync.Mutex
+	ok    bool
+	seen  []*vleaf // inputs of the cached value
+	value T
+}
+
+// Derive declares a value computed by fn, which must only depend on the values of the inputs.
+func Derive[T any](fn func(Context) T, inputs ...Input) *Derived[T] {
+	return &Derived[T]{fn: fn, inputs: inputs, seen: make([]*vleaf, len(inputs))}
+}
+
+// Value returns the value derived from ctx.
+// The value is only computed again if one of the inputs was set since the last call.
+func (d *Derived[T]) Value(ctx Context) T {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	fresh := d.ok
+	for i, in := range d.inputs {
+		// values are never modified in place, a new leaf means a new value
+		if l := ctx.vx.leaf(in.typ); l != d.seen[i] {
+			d.seen[i], fresh = l, false
+		}
+	}
+	if !fresh {
+		d.ok = false // if fn panics, the inputs are seen but the value is not cached
+		d.value, d.ok = d.fn(ctx), true
+	}
+	return d.value
+}
@@ -0,0 +1,64 @@
+package rx
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDerive(t *testing.T) {
+	type Rows []int
+	type Desc bool
+	type Hover int
+
+	runs := 0
+	sorted := Derive(func(ctx Context) []int {
+		runs++
+		rows := slices.Sorted(slices.Values(ValueOf[Rows](ctx)))
+		if ValueOf[Desc](ctx) {
+			slices.Reverse(rows)
+		}
+		return rows
+	}, In[Rows](), In[Desc]())
+
+	ctx := WithValues(Context{}, Rows{3, 1, 2})
+	steps := []struct {
+		act  Action
+		want []int
+		runs int
+	}{
+		{DoNothing, []int{1, 2, 3}, 1},
+		{LoadContext(Hover(1)), []int{1, 2, 3}, 1},
+		{LoadContext(Desc(true)), []int{3, 2, 1}, 2},
+		{LoadContext(Rows{4}), []int{4}, 3},
+		{DoNothing, []int{4}, 3},
+	}
+	for i, s := range steps {
+		ctx = s.act(ctx)
+		if got := sorted.Value(ctx); !slices.Equal(got, s.want) || runs != s.runs {
+			t.Errorf("step %d: got %v after %d runs, want %v after %d", i, got, runs, s.want, s.runs)
+		}
+	}
+
+	type N int
+	fail := false
+	double := Derive(func(ctx Context) int {
+		if fail {
+			panic("cannot derive")
+		}
+		return 2 * int(ValueOf[N](ctx))
+	}, In[N]())
+	value := func(ctx Context) (v int, panicked bool) {
+		defer func() { panicked = recover() != nil }()
+		return double.Value(ctx), false
+	}
+	value(WithValues(Context{}, N(5)))
+	ctx = WithValues(Context{}, N(2))
+	fail = true
+	if _, panicked := value(ctx); !panicked {
+		t.Fatal("derived function must panic")
+	}
+	fail = false
+	if got, _ := value(ctx); got != 4 {
+		t.Errorf("after a panic: got %d, want 4", got)
+	}
+}
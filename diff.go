@@ -0,0 +1,73 @@
+package rx
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"slices"
+	"unicode/utf8"
+)
+
+// ValueChange is a value which differs between two contexts.
+type ValueChange struct {
+	Key      any // type of the value, or [Key]
+	Old, New any // nil if the value is missing
+}
+
+func (c ValueChange) String() string {
+	return fmt.Sprintf("%s: %s → %s", c.Key, short(c.Old), short(c.New))
+}
+
+// short renders v on a few characters
+func short(v any) string {
+	const max = 40
+
+	if v == nil {
+		return "<nil>"
+	}
+	s := fmt.Sprintf("%v", v)
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:max-1]) + "…"
+}
+
+// Diff returns the values set in c, but not in other (or the reverse), sorted by key.
+// A value set again counts as a change, even if equal.
+// The cost is proportional to the number of changes, not the number of values.
+func (c Context) Diff(other Context) []ValueChange {
+	var out []ValueChange
+	for k := range other.vx.changed(c.vx) {
+		ch := ValueChange{Key: k}
+		ch.Old, _ = other.vx.get(k)
+		ch.New, _ = c.vx.get(k)
+		out = append(out, ch)
+	}
+	slices.SortFunc(out, func(a, b ValueChange) int { return cmp.Compare(fmt.Sprint(a.Key), fmt.Sprint(b.Key)) })
+	return out
+}
+
+// LogDiffs logs, at debug level, the values changed by each action.
+// It is registered when creating the engine:
+//
+//	rx.LogLevel.Set(slog.LevelDebug)
+//	ng := rx.New(root, rx.LogDiffs)
+func LogDiffs(ctx Context) Context {
+	ctx.ng.logdiffs = true
+	return ctx
+}
+
+// logDiff reports the values changed between before and after
+func (ng *Engine) logDiff(before, after Context) {
+	if after == noAction || after.vx == before.vx {
+		return
+	}
+
+	changes := after.Diff(before)
+	attrs := make([]any, len(changes))
+	for i, c := range changes {
+		attrs[i] = slog.String(fmt.Sprint(c.Key), short(c.Old)+" → "+short(c.New))
+	}
+	ng.logger.Debug("context changed", "intent", ng.IntentType, slog.Group("changes", attrs...))
+}
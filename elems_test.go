@@ -36,7 +36,7 @@ func TestHTML(t *testing.T) {
 		}
 
 		// run checks in serialize
-		serialize(got, new(etree), new(Counter), make(XAS, 0))
+		serialize(got, new(etree), new(vtree), new(Counter), make(XAS, 0))
 	}
 }
 
@@ -1,8 +1,12 @@
 package rx
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
-	"reflect"
+	"sync"
+	"time"
 )
 
 type Engine struct {
@@ -10,6 +14,7 @@ type Engine struct {
 	XAS     chan XAS
 
 	buf  XAS
+	pbuf XAS // scratch space for the alternative program (patch or rebuild)
 	free chan XAS
 
 	cnt Counter
@@ -17,18 +22,55 @@ type Engine struct {
 	logger     *slog.Logger
 	genHandler *genLogHandler
 
+	mx sync.Mutex // serializes turns between the action loop and Dispatch
+
+	// lifecycle, see [Engine.Close]
+	life    context.Context
+	stop    context.CancelFunc
+	done    chan struct{}
+	closing sync.Once
+	hooks   []func()
+
+	// commands started with [Go]
+	cmx  sync.Mutex
+	cmds map[any]*command
+
+	// see [Engine.Stats]
+	turn      TurnStats
+	drawtime  time.Duration // set by the draw loop, before returning the buffer
+	smx       sync.Mutex
+	last      TurnStats
+	turnHooks []func(TurnStats)
+
 	// access to all below is protected by inrenderpass Javascript lock
 
 	// these remember the previous state
 	et  etree
+	vt  vtree
 	gen int
-	ctx *vctx
+	ctx *vctx // committed state, as returned by the last action
+	history
+
+	storage    Storage
+	persisters []persister
+	watchers   []watcher
+	errHooks   []func(error) Action
+	failure    error // last action failure in the turn, see [Engine.transact]
 
 	k0, k1 *keyedEntity
 
+	ownframe   bool // set by [Immediate] actions
+	buildFault bool // set when an [ErrorBoundary] recovers from a panic in Build
+	logdiffs   bool // see [LogDiffs]
+
 	Root   RootWidget
 	Screen Coord
 	CallFrame
+
+	// RetargetStale delivers intents raised on the previous generation of the view (e.g. a click landing during a re-rendering)
+	// to the element now holding the same identity: through [Reuse] and [Keep], or an entity set explicitly.
+	// By default, for older intents, or if the element disappeared, the intent is dropped.
+	RetargetStale bool
 }
 
 type RootWidget Widget
@@ -40,6 +82,16 @@ type RootWidget Widget
 //	// finish initialization with ngx
 //	start()
 func New(root Widget, ctx ...Action) *Engine {
+	ng := newEngine(root, ctx...)
+	go ng.loop()
+
+	// empty action primes the loop
+
+	return ng
+}
+
+// newEngine initializes the engine, without starting the action loop.
+func newEngine(root Widget, ctx ...Action) *Engine {
 	ng := &Engine{
 		XAS:        make(chan XAS),
 		free:       make(chan XAS),
@@ -47,36 +99,116 @@ func New(root Widget, ctx ...Action) *Engine {
 		Root:       root,
 		genHandler: newLogHandler(),
 	}
-	ng.ctx = &vctx{kv: make(map[reflect.Type]any)}
+	ng.life, ng.stop = context.WithCancel(context.Background())
+	ng.done = make(chan struct{})
+	ng.logger = slog.New(ng.genHandler)
 	for _, f := range ctx {
-		ng.ctx = f(Context{vx: ng.ctx}).vx
+		ng.ctx = f(Context{ng: ng, vx: ng.ctx}).vx
 	}
-	ng.logger = slog.New(ng.genHandler)
+	return ng
+}
 
-	go func() {
-		for act := range ng.Actions {
-			if xas := ng.turncrank(act); xas != nil {
-				ng.XAS <- xas
-				ng.buf = <-ng.free // wait for the Return of the Buffer
+// loop executes actions until the engine is closed
+func (ng *Engine) loop() {
+	defer close(ng.done)
+	for {
+		select {
+		case act := <-ng.Actions:
+			if !ng.step(act) {
+				return
 			}
+		case <-ng.life.Done():
+			return
+		}
+	}
+}
 
-			// Note about the order: the continuation must be called synchronously
-			// so we can set correctly drag and drop data [dnd].
-			// Still, we make sure that the continuation happens after the view is updated.
-			// This is also happening even if no rendering happens (the NoAction context).
-			//
-			// [dnd] https://html.spec.whatwg.org/multipage/dnd.html#concept-dnd-rw
-			if ng.Continuation != nil {
-				ng.Continuation <- ng.CallFrame
-			}
+// step executes one turn of the engine, returning false if the engine was closed meanwhile
+func (ng *Engine) step(act Action) bool {
+	ng.mx.Lock()
+	defer ng.mx.Unlock()
 
-			ng.CallFrame = CallFrame{} // clear allow reacting to non-UI event
+	if xas := ng.turncrank(ng.batch(act)); xas != nil {
+		select {
+		case ng.XAS <- xas:
+		case <-ng.life.Done():
+			return false
 		}
-	}()
+		select {
+		case ng.buf = <-ng.free: // wait for the Return of the Buffer
+		case <-ng.life.Done():
+			return false
+		}
+	}
+	ng.publish()
+
+	// Note about the order: the continuation must be called synchronously
+	// so we can set correctly drag and drop data [dnd].
+	// Still, we make sure that the continuation happens after the view is updated.
+	// This is also happening even if no rendering happens (the NoAction context).
+	//
+	// [dnd] https://html.spec.whatwg.org/multipage/dnd.html#concept-dnd-rw
+	if ng.Continuation != nil {
+		select {
+		case ng.Continuation <- ng.CallFrame:
+		case <-ng.life.Done():
+			return false
+		}
+	}
 
-	// empty action primes the loop
+	ng.CallFrame = CallFrame{} // clear allow reacting to non-UI event
+	return true
+}
 
-	return ng
+// ErrClosed is returned when an intent is sent to a closed engine.
+var ErrClosed = errors.New("rx: engine closed")
+
+// closeTimeout is how long [Engine.Close] waits for the running action
+var closeTimeout = 5 * time.Second
+
+// Close stops the engine: running commands are cancelled, the action loop terminates,
+// the XAS channel is closed, and the hooks registered with [Engine.OnClose] are executed, last registered first.
+// Intents received afterwards are rejected with [ErrClosed].
+//
+// Close can be called multiple times, but not from within an action.
+// If the running action does not return within a few seconds, an error is returned and the engine is left stopping:
+// Close can be called again to finish the shutdown once the action returned.
+func (ng *Engine) Close() error {
+	if ng.life == nil {
+		return errors.New("rx: closing an engine not created with New")
+	}
+
+	ng.stop()
+	select {
+	case <-ng.done:
+	case <-time.After(closeTimeout):
+		return fmt.Errorf("rx: engine did not stop within %s, an action is still running", closeTimeout)
+	}
+
+	ng.closing.Do(func() {
+		ng.mx.Lock()
+		defer ng.mx.Unlock()
+
+		close(ng.XAS)
+		for i := len(ng.hooks) - 1; i >= 0; i-- {
+			ng.hooks[i]()
+		}
+		ng.hooks = nil
+
+		// release memory early, the engine might be referenced for longer
+		ng.buf, ng.pbuf = nil, nil
+		ng.et, ng.vt = etree{}, vtree{}
+		ng.k0, ng.k1 = nil, nil
+		ng.history = history{}
+	})
+	return nil
+}
+
+// OnClose registers a hook executed when the engine is closed.
+func (ng *Engine) OnClose(hook func()) {
+	ng.mx.Lock()
+	defer ng.mx.Unlock()
+	ng.hooks = append(ng.hooks, hook)
 }
 
 func Mouse_(ctx Context) Coord         { return ctx.ng.Mouse }
@@ -104,26 +236,73 @@ var noAction Context
 
 // turncrank executes all the systems in turn, and returns a virtual machine for the Javascript code to execute.
 // The render loop is not supposed to be executed solely based on a timing (e.g. every 60ms), but instead react to intents.
+// act is applied as is: failures must be handled by the caller, through [Engine.batch] or [Engine.transaction].
 func (ng *Engine) turncrank(act Action) XAS {
 	defer func() {
 		if r := recover(); r != nil {
 			ng.genHandler.Dump()
+			ng.abort()
 			panic(r)
 		}
 		ng.genHandler.Discard()
 	}()
 
+	ng.moved, ng.staged, ng.failure = nil, nil, nil
+	ng.turn = TurnStats{Gen: ng.gen}
 	ctx := act(Context{ng: ng, vx: ng.ctx})
 
 	if ctx == noAction {
+		ng.turn.Skipped = true
 		return nil
 	}
+	if len(ng.watchers) > 0 {
+		ctx = ng.watch(ctx)
+	}
 
+	if ng.gen > 0 { // the first rendering starts the history
+		ng.stage(ng.ctx, ctx.vx)
+	}
+
+	start := time.Now()
+	ng.buildFault = false
 	nd := ng.Root.Build(ctx)
-	ng.buf = serialize(nd, &ng.et, &ng.cnt, ng.buf[:0]).AddInstr(OpTerm)
+	if ng.buildFault {
+		// the failing widget must not leave the state half-updated:
+		// the turn is rolled back, and the boundaries render their fallback on the previous context
+		ctx, ng.moved, ng.staged = Context{ng: ng, vx: ng.ctx}, nil, nil
+		ng.cnt, ng.k0 = Counter(ng.gen&1), nil
+		nd = ng.Root.Build(ctx)
+	}
+	ng.turn.Build = time.Since(start)
+
+	start = time.Now()
+	ng.buf = serialize(nd, &ng.et, &ng.vt, &ng.cnt, ng.buf[:0]).AddInstr(OpTerm)
+
+	// patching the DOM in place preserves focus, selection and scroll,
+	// but a full rebuild is cheaper when most of the tree changed.
+	if patch := ng.vt.diff(ng.pbuf[:0]); patch != nil && len(patch) < len(ng.buf) {
+		ng.buf, ng.pbuf = patch, ng.buf
+		ng.turn.Patch = true
+	} else if patch != nil {
+		ng.pbuf = patch
+	}
+	ng.turn.Serialize = time.Since(start)
+	ng.turn.Nodes, ng.turn.Entities, ng.turn.XAS = poolLen(), len(ng.et.g0), len(ng.buf)
 
+	ng.commit()
+	ng.persist(ng.ctx, ctx.vx)
 	ng.ctx = ctx.vx
+	if ng.RetargetStale {
+		for p := ng.k0; p != nil; p = p.next {
+			for q := ng.k1; q != nil; q = q.next {
+				if q.key == p.key && q.val != p.val {
+					ng.et.rename(q.val, p.val)
+				}
+			}
+		}
+	}
 	ng.et.ngen()
+	ng.vt.ngen()
 	ng.gen++
 	ng.cnt = Counter(ng.gen & 1)
 	ng.k0, ng.k1 = nil, ng.k0
@@ -132,38 +311,130 @@ func (ng *Engine) turncrank(act Action) XAS {
 	return ng.buf
 }
 
+// mount is the action of the first turn, rendering the root widget.
+// It is the same on the server (see [Engine.RenderHTML]) and in the browser, so the entities match.
+func (ng *Engine) mount(ctx Context) Context { return WithValue(ctx, ng.Root) }
+
+// abort discards the partial state of an interrupted turn, so the next one starts afresh.
+func (ng *Engine) abort() {
+	ng.et.discard()
+	ng.vt.discard()
+	ng.cnt = Counter(ng.gen & 1)
+	ng.k0 = nil
+	freePool()
+}
+
+// batch applies the action, then all actions already pending, in order.
+// A burst of actions (e.g. several network responses arriving together) thus results in a single rendering.
+//
+// The batch stops after an action which needs its own frame:
+// an intent with a continuation (e.g. a drag start), or an [Immediate] action.
+func (ng *Engine) batch(act Action) Action {
+	return func(ctx Context) Context {
+		out := noAction
+		ng.ownframe = false
+		for first := true; ; first = false {
+			if c := ng.transact(act, ctx); c != noAction {
+				ctx, out = c, c
+			}
+			if first {
+				ng.turn.Intent = ng.IntentType // the call frame is cleared by the following actions
+			}
+			if ng.Continuation != nil || ng.ownframe {
+				return out
+			}
+
+			select {
+			case act = <-ng.Actions:
+				ng.CallFrame = CallFrame{}
+			default:
+				return out
+			}
+		}
+	}
+}
+
+// Immediate marks an action as needing its own frame:
+// it is rendered as soon as it is applied, without waiting for other pending actions.
+func Immediate(act Action) Action {
+	return func(ctx Context) Context {
+		ctx.ng.ownframe = true
+		return act(ctx)
+	}
+}
+
 // ReleaseXAS is used by the main routine to prevent too much allocations
-func (ng *Engine) ReleaseXAS(buf XAS) { ng.free <- buf }
+func (ng *Engine) ReleaseXAS(buf XAS) {
+	select {
+	case ng.free <- buf:
+	case <-ng.life.Done():
+	}
+}
+
+// ReactToIntent transforms a JS call into an action, triggering the next rendering cycle.
+// It returns [ErrClosed] if the engine is closed.
+func (ng *Engine) ReactToIntent(cf CallFrame) error {
+	select {
+	case ng.Actions <- ng.intent(cf):
+		return nil
+	case <-ng.life.Done():
+		return ErrClosed
+	}
+}
 
-// ReactToIntent transforms a JS call into an action, triggering the next rendering cycle
-func (ng *Engine) ReactToIntent(cf CallFrame) {
-	do := func(ctx Context) Context {
+// intent returns the action executing the handler of the intent in cf.
+// The handler is looked up from the entity up to the root of the entity tree.
+func (ng *Engine) intent(cf CallFrame) Action {
+	return func(ctx Context) Context {
 		if cf.Gen != ng.gen {
-			return ctx
+			nt, ok := ng.retarget(cf)
+			if !ok {
+				ng.CallFrame = cf // still answer the continuation, if any
+				return noAction
+			}
+			cf.Entity, cf.Gen = nt, ng.gen
 		}
 		ng.CallFrame = cf
 
-		var h intentHandler
 		for _, nt := range ng.et.parents(cf.Entity) {
-			if nt.hdl[cf.IntentType] != nil {
-				h = nt.hdl
-				break
+			if h := nt.hdl.get(cf.IntentType); h != nil {
+				return h(ctx)
 			}
 		}
+		return noAction
+	}
+}
 
-		if h[cf.IntentType] == nil {
-			return noAction
-		}
-		return h[cf.IntentType](ctx)
-
+// retarget maps the entity of a stale intent to the current generation.
+// Only renames from the previous generation are known, older intents are dropped:
+// automatic entities are numbered again every other generation, and would reach another element.
+func (ng *Engine) retarget(cf CallFrame) (Entity, bool) {
+	if !ng.RetargetStale {
+		return 0, false
+	}
+	drop := func(reason string) (Entity, bool) {
+		ng.logger.Debug("dropping stale intent", "reason", reason,
+			"intent", cf.IntentType, "entity", cf.Entity, "gen", cf.Gen, "current", ng.gen)
+		return 0, false
 	}
 
-	ng.Actions <- Action(do)
+	if cf.Gen != ng.gen-1 {
+		return drop("more than one generation old")
+	}
+	if nt, ok := ng.et.r1[cf.Entity]; ok {
+		return nt, true
+	}
+	// automatic entities of the previous generation have the other parity, so this is an explicit entity
+	if ng.et.locate(cf.Entity) != -1 {
+		return cf.Entity, true
+	}
+	return drop("target disappeared")
 }
 
 type IntentType int
 
 //go:generate go tool stringer -type IntentType
+//go:generate gofmt -w -r "\"IntentType(\" + strconv.FormatInt(int64(i), 10) + \")\" -> intentName(i, \"IntentType(\"+strconv.FormatInt(int64(i), 10)+\")\")" intenttype_string.go
 //go:generate go tool rxabi -type IntentType
 const (
 	NoIntent IntentType = iota
@@ -184,7 +455,7 @@ const (
 	ShowDebugMenu
 	CellSizeChange
 	Submit
-	Seppuku // must be last, used to size intentHandler
+	Seppuku // must be last, see [RegisterIntent]
 	// run "go generate ./..." after updating this list
 )
 
@@ -0,0 +1,253 @@
+package rx
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBatchActions(t *testing.T) {
+	type count int
+	inc := func(ctx Context) Context { return WithValue(ctx, ValueOf[count](ctx)+1) }
+	skip := func(Context) Context { return noAction }
+
+	ng := &Engine{Actions: make(chan Action, 4)}
+	ng.Actions <- skip
+	ng.Actions <- inc
+	ng.Actions <- Immediate(inc)
+	ng.Actions <- inc
+
+	ctx := ng.batch(inc)(Context{ng: ng})
+	if got := ValueOf[count](ctx); got != 3 {
+		t.Errorf("want 3 actions in batch, got %d", got)
+	}
+	if len(ng.Actions) != 1 {
+		t.Errorf("batch must stop after an immediate action, %d pending", len(ng.Actions))
+	}
+
+	<-ng.Actions
+	ng.Actions <- skip
+	if ctx := ng.batch(skip)(Context{ng: ng}); ctx != noAction {
+		t.Errorf("batch without changes must not render")
+	}
+}
+
+func TestCloseEngine(t *testing.T) {
+	for range 50 {
+		ng := New(WidgetFunc(func(ctx Context) *Node { return Get(`<div>`) }))
+		var closed bool
+		ng.OnClose(func() { closed = true })
+
+		ng.Actions <- DoNothing
+		ng.ReleaseXAS(<-ng.XAS)
+
+		if err := ng.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := ng.Close(); err != nil {
+			t.Fatalf("closing again: %v", err)
+		}
+
+		select {
+		case <-ng.done:
+		default:
+			t.Fatal("action loop still running")
+		}
+		if !closed {
+			t.Fatal("close hook not executed")
+		}
+		if _, ok := <-ng.XAS; ok {
+			t.Fatal("XAS channel still open")
+		}
+		if err := ng.ReactToIntent(CallFrame{IntentType: Click}); err != ErrClosed {
+			t.Fatalf("intent after close: want ErrClosed, got %v", err)
+		}
+	}
+
+	if err := new(Engine).Close(); err == nil {
+		t.Errorf("closing a zero engine must fail")
+	}
+}
+
+func TestCloseBlocked(t *testing.T) {
+	defer func(d time.Duration) { closeTimeout = d }(closeTimeout)
+	closeTimeout = 10 * time.Millisecond
+
+	ng := New(WidgetFunc(func(ctx Context) *Node { return Get(`<div>`) }))
+	running, release := make(chan struct{}), make(chan struct{})
+	ng.Actions <- func(ctx Context) Context {
+		close(running)
+		<-release
+		return ctx
+	}
+	<-running
+
+	if err := ng.Close(); err == nil {
+		t.Fatal("close must fail while an action is running")
+	}
+	close(release)
+	<-ng.done
+	if err := ng.Close(); err != nil {
+		t.Errorf("close after the action returned: %v", err)
+	}
+}
+
+func TestRetargetStale(t *testing.T) {
+	type kept struct{}
+	type clicked Entity
+	const explicit Entity = 1001
+
+	var item Entity
+	show := true
+	ng := New(WidgetFunc(func(ctx Context) *Node {
+		onClick := func(ctx Context) Context { return WithValue(ctx, clicked(Entity_(ctx))) }
+		li := Get(`<li>`).GiveKey(ctx).OnIntent(Click, onClick)
+		item = li.Entity
+		ul := Get(`<ul>`).AddChildren(reuseOrKeep[kept](ctx).OnIntent(Click, onClick), li)
+		if show {
+			fixed := Get(`<li>`).OnIntent(Click, onClick)
+			fixed.Entity = explicit
+			ul.AddChildren(fixed)
+		}
+		return ul
+	}))
+	defer ng.Close()
+	ng.mx.Lock()
+	defer ng.mx.Unlock()
+
+	ng.turncrank(DoNothing)
+	stale := CallFrame{IntentType: Click, Entity: ng.k1.val, Gen: ng.gen}
+	ng.turncrank(DoNothing) // a network update re-renders the view
+
+	if ng.turncrank(ng.intent(stale)) != nil {
+		t.Errorf("stale intent must be dropped by default")
+	}
+
+	ng.RetargetStale = true
+	clickedOn := func(cf CallFrame) Entity {
+		ng.turncrank(ng.intent(cf))
+		return Entity(ValueOf[clicked](Context{vx: ng.ctx}))
+	}
+
+	ng.turncrank(DoNothing)
+	stale = CallFrame{IntentType: Click, Entity: ng.k1.val, Gen: ng.gen}
+	ng.turncrank(DoNothing)
+	want := ng.k1.val
+	if got := clickedOn(stale); got != want || want == stale.Entity {
+		t.Errorf("stale intent on kept element %d retargeted to %d, want %d", stale.Entity, got, want)
+	}
+
+	stale = CallFrame{IntentType: Click, Entity: explicit, Gen: ng.gen}
+	ng.turncrank(DoNothing)
+	if got := clickedOn(stale); got != explicit {
+		t.Errorf("stale intent on explicit entity retargeted to %d", got)
+	}
+
+	// automatic entities only match by position, which is not an identity
+	stale = CallFrame{IntentType: Click, Entity: item, Gen: ng.gen}
+	ng.turncrank(DoNothing)
+	if ng.turncrank(ng.intent(stale)) != nil {
+		t.Errorf("intent on automatic entity %d must be dropped", stale.Entity)
+	}
+
+	stale = CallFrame{IntentType: Click, Entity: ng.k1.val, Gen: ng.gen}
+	ng.turncrank(DoNothing)
+	ng.turncrank(DoNothing)
+	if ng.turncrank(ng.intent(stale)) != nil {
+		t.Errorf("intent older than one generation must be dropped")
+	}
+
+	stale = CallFrame{IntentType: Click, Entity: explicit, Gen: ng.gen}
+	show = false
+	ng.turncrank(DoNothing)
+	if ng.turncrank(ng.intent(stale)) != nil {
+		t.Errorf("intent on disappeared element must be dropped")
+	}
+}
+
+func TestRegisterIntent(t *testing.T) {
+	input := RegisterIntent("test-input", "target.value")
+	if again := RegisterIntent("test-input", "target.value"); again != input {
+		t.Errorf("registering again: got intent %d, want %d", again, input)
+	}
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("registering again with other registers must panic")
+			}
+		}()
+		RegisterIntent("test-input", "target.checked")
+	}()
+	if input <= Seppuku {
+		t.Errorf("registered intent %d overlaps the built-in ones", input)
+	}
+	if got := input.String(); got != "test-input" {
+		t.Errorf("name of registered intent: got %s", got)
+	}
+	if got := IntentType(1000).String(); got != "IntentType(1000)" {
+		t.Errorf("name of unknown intent: got %s", got)
+	}
+
+	type typed string
+	var field Entity
+	ng := New(WidgetFunc(func(ctx Context) *Node {
+		in := Get(`<input>`).GiveKey(ctx)
+		field = in.Entity
+		return Get(`<form>`).OnIntent(input, Set[typed]("hello")).AddChildren(
+			in.OnIntent(Click, DoNothing),
+		)
+	}))
+	defer ng.Close()
+	ng.mx.Lock()
+	defer ng.mx.Unlock()
+
+	ng.turncrank(DoNothing)
+	ng.turncrank(ng.intent(CallFrame{IntentType: input, Entity: field, Gen: ng.gen}))
+	if got := ValueOf[typed](Context{vx: ng.ctx}); got != "hello" {
+		t.Errorf("registered intent not handled by parent: got %q", got)
+	}
+	if ng.turncrank(ng.intent(CallFrame{IntentType: input + 1, Entity: field, Gen: ng.gen})) != nil {
+		t.Errorf("unknown intent must not be handled")
+	}
+}
+
+func TestTurnStats(t *testing.T) {
+	ng := New(WidgetFunc(func(ctx Context) *Node {
+		ul := Get(`<ul><li>1</li><li>2</li></ul>`) // children allocated from the pool
+		for _, li := range ul.Children {
+			li.OnIntent(Click, DoNothing)
+		}
+		return ul
+	}))
+	defer ng.Close()
+
+	turns := make(chan TurnStats, 2)
+	ng.OnTurn(func(st TurnStats) { turns <- st })
+
+	ng.Actions <- DoNothing
+	xas := <-ng.XAS
+	ng.ReleaseXAS(xas)
+	st := <-turns
+	if st.Skipped || st.Nodes != 2 || st.Entities != 2 || st.XAS != len(xas) {
+		t.Errorf("invalid statistics for first turn: %+v", st)
+	}
+	if ng.Stats() != st {
+		t.Errorf("Stats: got %+v, want %+v", ng.Stats(), st)
+	}
+
+	ng.Actions <- func(Context) Context { return noAction }
+	if st := <-turns; !st.Skipped || st.Gen != 1 {
+		t.Errorf("skipped turn not reported: %+v", st)
+	}
+}
+
+func TestTurnIntent(t *testing.T) {
+	ng := newEngine(WidgetFunc(func(ctx Context) *Node { return Get(`<button>`).OnIntent(Click, DoNothing) }))
+	ng.Actions = make(chan Action, 1)
+	ng.turncrank(DoNothing)
+
+	ng.Actions <- DoNothing // e.g. a network response, batched with the click
+	ng.turncrank(ng.batch(ng.intent(CallFrame{IntentType: Click, Entity: ng.et.g1[0].ntt, Gen: ng.gen})))
+	if ng.turn.Intent != Click {
+		t.Errorf("triggering intent: got %s, want %s", ng.turn.Intent, Click)
+	}
+}
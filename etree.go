@@ -8,12 +8,31 @@ import (
 type prenode struct {
 	ntt Entity
 	scp int
+	par int // index of the parent entity, -1 at the root
 	hdl intentHandler
 }
 
-type intentHandler [Seppuku]func(Context) Context
+// intentHandler holds the handlers of a node, indexed by intent type.
+// It only grows up to the last intent handled, so [RegisterIntent] does not make all nodes larger.
+type intentHandler []func(Context) Context
 
-func (i *intentHandler) Some() bool {
+func (i intentHandler) get(t IntentType) func(Context) Context {
+	if t < 0 || int(t) >= len(i) {
+		return nil
+	}
+	return i[t]
+}
+
+// set replaces the handlers by a copy holding h at t.
+// Nodes copied by value share their handlers, so they are never updated in place.
+func (i *intentHandler) set(t IntentType, h func(Context) Context) {
+	c := make(intentHandler, max(len(*i), int(t)+1))
+	copy(c, *i)
+	c[t] = h
+	*i = c
+}
+
+func (i intentHandler) Some() bool {
 	for _, h := range i {
 		if h != nil {
 			return true
@@ -25,8 +44,13 @@ func (i *intentHandler) Some() bool {
 // etree is a bi-generational tree structure.
 // append are done on g0, and read on g1.
 // it is used in the engine, where each turn of the crank results in a new gen
+//
+// each generation is indexed by entity, so lookups do not depend on the size of the tree.
 type etree struct {
 	g0, g1 []prenode
+	i0, i1 map[Entity]int
+	r0, r1 map[Entity]Entity // renames from the previous generation, see [etree.rename]
+	open   []int             // entities in g0 whose scope is not closed yet
 }
 
 // ngen starts recording a new generation of entities
@@ -34,23 +58,53 @@ type etree struct {
 func (t *etree) ngen() {
 	t.g1, t.g0 = t.g0, t.g1[:0]
 	clear(t.g0) // release handlers
+	t.i1, t.i0 = t.i0, t.i1
+	clear(t.i0)
+	t.r1, t.r0 = t.r0, t.r1
+	clear(t.r0)
+	t.open = t.open[:0]
+}
+
+// discard drops the generation being recorded
+func (t *etree) discard() {
+	clear(t.g0)
+	t.g0 = t.g0[:0]
+	clear(t.i0)
+	clear(t.r0)
+	t.open = t.open[:0]
 }
 
 // add adds an entity to the current tree.
-// by default, the entity is open on the left: you must call [etree.closeLeft] if the entity does not have children
+// by default, the entity is open on the left: you must call [etree.closeScope] once all its children are added.
 func (t *etree) add(nt Entity) int {
-	assert(nt < 10240, "cannot store more than 10240 entities in event handler")
-
-	t.g0 = append(t.g0, prenode{ntt: nt})
+	t.g0 = append(t.g0, prenode{ntt: nt, par: t.parent()})
+	t.index(nt, len(t.g0)-1)
+	t.open = append(t.open, len(t.g0)-1)
 	return len(t.g0) - 1
 }
 
+// parent returns the index of the innermost open entity
+func (t *etree) parent() int {
+	if len(t.open) == 0 {
+		return -1
+	}
+	return t.open[len(t.open)-1]
+}
+
+func (t *etree) index(nt Entity, at int) {
+	if t.i0 == nil {
+		t.i0 = make(map[Entity]int)
+	}
+	t.i0[nt] = at
+}
+
 // reuse carries from the previous generation a sub-tree
 // it performs entity renaming, and calls the it function on each rename
 func (t *etree) reuse(from, to Entity, c *Counter, it func(from, to Entity)) {
 	start := len(t.g0)
-	// help with buggy client code
-	assert(start != -1, "reusing invalid entity %d", from)
+	old := t.locate(from)
+	// help with buggy client code, see [Reuse]
+	assert(old != -1, "reusing entity %d, not rendered in the previous generation", from)
 
 	t.g0 = append(t.g0, t.children(from)...)
 
@@ -59,18 +113,38 @@ func (t *etree) reuse(from, to Entity, c *Counter, it func(from, to Entity)) {
 		if i == 0 {
 			// first node can have an explicit rename due to client capturing the new node
 			nt = to
+			t.g0[start].par = t.parent()
 		} else {
 			nt = c.Inc()
+			t.g0[start+i].par += start - old
 		}
 		if nt != t.g0[start+i].ntt {
 			it(t.g0[start+i].ntt, nt)
+			t.rename(t.g0[start+i].ntt, nt)
 		}
 		t.g0[start+i].ntt = nt
+		t.index(nt, start+i)
+	}
+}
+
+// rename records that entity from, in the previous generation, is now entity to.
+// The first rename of an entity wins.
+func (t *etree) rename(from, to Entity) {
+	if t.r0 == nil {
+		t.r0 = make(map[Entity]Entity)
+	}
+	if _, ok := t.r0[from]; !ok {
+		t.r0[from] = to
 	}
 }
 
 func (t *etree) addHandler(hdl intentHandler) { t.g0[len(t.g0)-1].hdl = hdl }
-func (t *etree) closeScope(of int)            { t.g0[of].scp = len(t.g0) - of }
+
+func (t *etree) closeScope(of int) {
+	assert(t.parent() == of, "closing entity %d out of order", t.g0[of].ntt)
+	t.g0[of].scp = len(t.g0) - of
+	t.open = t.open[:len(t.open)-1]
+}
 
 // children returns the subtree rooted at entity nt (including the entity itself).
 // if the entity does not exist, it returns a nil value.
@@ -83,6 +157,7 @@ func (t *etree) children(nt Entity) []prenode {
 	return t.g1[i : i+t.g1[i].scp]
 }
 
+// parents returns the chain of entities from nt (included) up to the root.
 func (t *etree) parents(nt Entity) []prenode {
 	i := t.locate(nt)
 	assert(i != -1, `entity %d does not exist in the element tree. 
@@ -90,20 +165,16 @@ func (t *etree) parents(nt Entity) []prenode {
 	You may have passed an invalid entity alongside a datacell intent.
 	I prefer to bail out.`, nt)
 
-	chain := make([]prenode, 0, i)
-	for j := i; j >= 0; j-- {
-		if t.g1[j].scp+j > i {
-			chain = append(chain, t.g1[j])
-		}
+	var chain []prenode
+	for ; i != -1; i = t.g1[i].par {
+		chain = append(chain, t.g1[i])
 	}
 	return chain
 }
 
 func (t *etree) locate(nt Entity) int {
-	for i := range t.g1 {
-		if t.g1[i].ntt == nt {
-			return i
-		}
+	if i, ok := t.i1[nt]; ok {
+		return i
 	}
 	return -1
 }
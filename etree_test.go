@@ -166,3 +166,68 @@ func readNodes(short string) *Node {
 
 	return stack[:1][0]
 }
+
+func TestReuseETree(t *testing.T) {
+	var et etree
+	loadtree(readNodes("(2(4, 6(8)))"), &et)
+
+	cnt := Counter(3) // 1 and 3 are taken
+	idx := et.add(1)
+	renames := make(map[Entity]Entity)
+	et.reuse(6, 3, &cnt, func(from, to Entity) { renames[from] = to })
+	et.closeScope(idx)
+	et.ngen()
+
+	want := map[Entity]Entity{6: 3, 8: 5}
+	if !cmp.Equal(renames, want) {
+		t.Errorf("renames: %s", cmp.Diff(want, renames))
+	}
+	if got := pntt(et.parents(5)); !cmp.Equal(got, []Entity{5, 3, 1}) {
+		t.Errorf("parents of reused entity: got %v", got)
+	}
+}
+
+// intent dispatch must not depend on the number of entities in the tree
+func BenchmarkIntentLookup(b *testing.B) {
+	for _, rows := range []int{100, 10_000, 100_000} {
+		b.Run(strconv.Itoa(rows), func(b *testing.B) {
+			var et etree
+			cnt := Counter(0)
+			tbl := et.add(cnt.Inc())
+			for range rows {
+				row := et.add(cnt.Inc())
+				for range 3 {
+					et.closeScope(et.add(cnt.Inc()))
+				}
+				et.closeScope(row)
+			}
+			et.closeScope(tbl)
+			et.ngen()
+
+			last := Entity(cnt)
+			b.ResetTimer()
+			for range b.N {
+				if len(et.parents(last)) != 3 {
+					b.Fatal("invalid parent chain")
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkBuildETree(b *testing.B) {
+	for _, rows := range []int{100, 10_000, 100_000} {
+		b.Run(strconv.Itoa(rows), func(b *testing.B) {
+			var et etree
+			for range b.N {
+				cnt := Counter(0)
+				tbl := et.add(cnt.Inc())
+				for range rows {
+					et.closeScope(et.add(cnt.Inc()))
+				}
+				et.closeScope(tbl)
+				et.ngen()
+			}
+		})
+	}
+}
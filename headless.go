@@ -0,0 +1,70 @@
+//go:build !js
+
+package rx
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Dispatch executes a turn of the engine synchronously, reacting to the intent in cf.
+// This is used to drive an engine without a browser, e.g. in unit tests:
+//
+//	ng := rx.New(root)
+//	ng.Dispatch(rx.CallFrame{}) // first rendering
+//	_, ret, err := ng.Dispatch(rx.CallFrame{IntentType: rx.Click, Entity: btn})
+//
+// The intent always targets the current generation, and a [NoIntent] call frame only renders the root widget.
+// Registers are plain Go values (see [Val]); the values set with [S1]…[S4] are returned unwrapped.
+//
+// The returned program is nil if nothing was rendered, and is owned by the caller.
+// A failure of the intent handler (see [Abort]), or a panic during rendering, is returned as an error.
+// Once the engine is closed, [ErrClosed] is returned.
+func (ng *Engine) Dispatch(cf CallFrame) (xas XAS, ret [4]any, err error) {
+	ng.mx.Lock()
+	defer ng.mx.Unlock()
+	if ng.life.Err() != nil {
+		return nil, ret, ErrClosed
+	}
+	defer func() { ng.CallFrame = CallFrame{} }()
+
+	cf.Gen = ng.gen
+	for i := range cf.Registers {
+		if cf.Registers[i] == nil {
+			cf.Registers[i] = Val("")
+		}
+	}
+
+	act := ng.intent(cf)
+	if cf.IntentType == NoIntent {
+		ng.CallFrame, act = cf, DoNothing
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("dispatching %s on entity %d: %v", cf.IntentType, cf.Entity, r)
+			}
+		}()
+		xas = slices.Clone(ng.turncrank(ng.transaction(act)))
+		ng.turn.Intent = cf.IntentType
+		ng.publish()
+	}()
+	if ng.failure != nil {
+		err = fmt.Errorf("dispatching %s on entity %d: %w", cf.IntentType, cf.Entity, ng.failure)
+	}
+
+	for i, v := range ng.Returns {
+		if v == nil {
+			continue
+		}
+		gv, ok := v.(goValue)
+		if !ok {
+			err = errors.Join(err, fmt.Errorf("dispatching %s on entity %d: return value %d is not a Go value (%T)", cf.IntentType, cf.Entity, i+1, v))
+			continue
+		}
+		ret[i] = gv.v
+	}
+	return xas, ret, err
+}
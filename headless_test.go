@@ -0,0 +1,87 @@
+//go:build !js
+
+package rx
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDispatch(t *testing.T) {
+	type clicks int
+
+	var btn Entity
+	ng := New(WidgetFunc(func(ctx Context) *Node {
+		b := Get(`<button>`).SetText(strings.Repeat("+", int(ValueOf[clicks](ctx)))).
+			OnIntent(Click, func(ctx Context) Context {
+				S1(ctx, "clicked "+R1(ctx))
+				return WithValue(ctx, ValueOf[clicks](ctx)+1)
+			}).
+			OnIntent(DoubleClick, func(ctx Context) Context { panic("boom") })
+		b.GiveKey(ctx)
+		btn = b.Entity
+		return b
+	}))
+
+	if xas, _, err := ng.Dispatch(CallFrame{}); err != nil || xas == nil {
+		t.Fatalf("first rendering: %v (program %v)", err, xas)
+	}
+
+	cf := CallFrame{IntentType: Click, Entity: btn}
+	cf.Registers[0] = Val("once")
+	xas, ret, err := ng.Dispatch(cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret[0] != "clicked once" {
+		t.Errorf("continuation output: got %v", ret)
+	}
+	if !strings.Contains(string(xas), "+") {
+		t.Errorf("new state not rendered: %q", xas)
+	}
+
+	if _, _, err := ng.Dispatch(CallFrame{IntentType: DoubleClick, Entity: btn}); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("handler panic not surfaced, got %v", err)
+	}
+
+	if _, _, err := ng.Dispatch(CallFrame{IntentType: Click, Entity: btn}); err != nil {
+		t.Errorf("engine unusable after a panic: %v", err)
+	}
+	if got := ValueOf[clicks](Context{vx: ng.ctx}); got != 2 {
+		t.Errorf("want 2 clicks, got %d", got)
+	}
+}
+
+// jsValue is a value returned by the browser, not a Go value
+type jsValue struct{ JSValue }
+
+func TestDispatchReturns(t *testing.T) {
+	var btn Entity
+	ng := New(WidgetFunc(func(ctx Context) *Node {
+		b := Get(`<button>`).GiveKey(ctx).OnIntent(Click, func(ctx Context) Context {
+			ctx.ng.Returns[1] = jsValue{}
+			return ctx
+		})
+		btn = b.Entity
+		return b
+	}))
+	defer ng.Close()
+
+	ng.Dispatch(CallFrame{})
+	if _, _, err := ng.Dispatch(CallFrame{IntentType: Click, Entity: btn}); err == nil || !strings.Contains(err.Error(), "not a Go value") {
+		t.Errorf("invalid return value not reported, got %v", err)
+	}
+}
+
+func TestValTruthy(t *testing.T) {
+	for _, v := range []any{nil, false, "", 0, 0.0, float32(0), uint8(0), []int(nil)} {
+		if Val(v).Truthy() {
+			t.Errorf("%T %v must be falsy", v, v)
+		}
+	}
+	for _, v := range []any{true, "0", 1, 0.5, float32(-1), []int{}} {
+		if !Val(v).Truthy() {
+			t.Errorf("%T %v must be truthy", v, v)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package rx
+
+import (
+	"reflect"
+	"slices"
+)
+
+// history of the committed contexts, see [Undo]
+type history struct {
+	past, future []*vctx
+	limit        int
+	transient    map[any]bool
+
+	// both reset each turn, and applied when it is committed
+	moved  *travel // staged by [Undo] and [Redo]
+	staged *travel // history after the turn, computed before building the view
+}
+
+// travel is a move in the history, staged until the turn is committed.
+// It is replaced, never updated, so the engine rolls it back with the action (see [Engine.transact]).
+type travel struct {
+	past, future []*vctx
+	at           *vctx // state restored by the move
+}
+
+const defaultHistorySize = 100
+
+// stage computes the history after a turn from old to cur, so widgets see it (see [CanUndo]).
+// It is applied by [history.commit].
+func (h *history) stage(old, cur *vctx) {
+	s := travel{past: h.past, future: h.future, at: old}
+	if h.moved != nil {
+		s = *h.moved
+	}
+	s.past, s.future = h.push(s.past, s.future, s.at, cur)
+	h.staged = &s
+}
+
+// commit applies the staged history
+func (h *history) commit() {
+	if h.staged != nil {
+		h.past, h.future = h.staged.past, h.staged.future
+	}
+	h.moved, h.staged = nil, nil
+}
+
+// current returns the stacks as seen by the turn
+func (h *history) current() (past, future []*vctx) {
+	switch {
+	case h.staged != nil:
+		return h.staged.past, h.staged.future
+	case h.moved != nil:
+		return h.moved.past, h.moved.future
+	}
+	return h.past, h.future
+}
+
+// push returns past with old added if cur holds other values, unless only transient values changed.
+// The slices are copied, never updated in place, since the turn can still be discarded.
+func (h *history) push(past, future []*vctx, old, cur *vctx) ([]*vctx, []*vctx) {
+	if old == cur || h.limit < 0 || !h.changed(old, cur) {
+		return past, future
+	}
+
+	limit := h.limit
+	if limit == 0 {
+		limit = defaultHistorySize
+	}
+	if len(past) >= limit {
+		past = past[len(past)-limit+1:]
+	}
+	return append(slices.Clone(past), old), nil
+}
+
+// changed reports if a value which is not transient differs between old and cur.
+// Values set again, but equal, are not a change.
+func (h *history) changed(old, cur *vctx) bool {
+	for k := range old.changed(cur) {
+		if h.transient[k] {
+			continue
+		}
+		ov, _ := old.get(k)
+		cv, _ := cur.get(k)
+		if !reflect.DeepEqual(ov, cv) {
+			return true
+		}
+	}
+	return false
+}
+
+// move stages a move in history from cur, after recording the changes made since the last move (or the committed state).
+// It returns nil if there is no state to move to.
+func (h *history) move(committed, cur *vctx, undo bool) *travel {
+	m := &travel{past: h.past, future: h.future, at: committed}
+	if h.moved != nil {
+		*m = *h.moved
+	}
+	m.past, m.future = h.push(m.past, m.future, m.at, cur)
+
+	from, to := m.past, m.future
+	if !undo {
+		from, to = to, from
+	}
+	if len(from) == 0 {
+		return nil
+	}
+	next := from[len(from)-1]
+	from, to = from[:len(from)-1], append(slices.Clone(to), cur)
+	if !undo {
+		from, to = to, from
+	}
+	m.past, m.future = from, to
+	m.at = h.restore(next, cur)
+	return m
+}
+
+// restore returns the state to, keeping the transient values of cur
+func (h *history) restore(to, cur *vctx) *vctx {
+	for k := range h.transient {
+		if v, ok := cur.get(k); ok {
+			to = to.with(k, v)
+		}
+	}
+	return to
+}
+
+// Undo restores the state committed before the last change.
+// The values of [Transient] types are kept as they are.
+// If there is nothing to undo, nothing is rendered.
+func Undo(ctx Context) Context { return travelTo(ctx, true) }
+
+// Redo restores the state undone by the last [Undo].
+// Any new change clears the states which can be redone.
+func Redo(ctx Context) Context { return travelTo(ctx, false) }
+
+func travelTo(ctx Context, undo bool) Context {
+	ng := ctx.ng
+	m := ng.history.move(ng.ctx, ctx.vx, undo)
+	if m == nil {
+		return noAction
+	}
+	ng.history.moved = m
+	ctx.vx = m.at
+	return ctx
+}
+
+// CanUndo returns true if [Undo] has a state to restore, e.g. to disable a button.
+// When building the view, it includes the changes of the current turn.
+func CanUndo(ctx Context) bool {
+	past, _ := ctx.ng.history.current()
+	return len(past) > 0
+}
+
+// CanRedo returns true if [Redo] has a state to restore.
+func CanRedo(ctx Context) bool {
+	_, future := ctx.ng.history.current()
+	return len(future) > 0
+}
+
+// Transient excludes values of type T, or at key if given, from the history:
+// changing them alone does not record a new state,
+// and they are not restored by [Undo] or [Redo] (e.g. the element under the mouse).
+// It is registered when creating the engine:
+//
+//	ng := rx.New(root, rx.Transient[Hover]())
+func Transient[T any](at ...*Key[T]) Action {
+	return func(ctx Context) Context {
+		h := &ctx.ng.history
+		if h.transient == nil {
+			h.transient = make(map[any]bool)
+		}
+		h.transient[keyOf(at)] = true
+		return ctx
+	}
+}
+
+// HistorySize sets the number of states kept for [Undo] (100 by default).
+// A negative size disables the history.
+func HistorySize(size int) Action {
+	return func(ctx Context) Context {
+		h := &ctx.ng.history
+		h.limit = size
+		if size > 0 && len(h.past) > size {
+			h.past = h.past[len(h.past)-size:]
+		}
+		return ctx
+	}
+}
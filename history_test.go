@@ -0,0 +1,138 @@
+package rx
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestUndoRedo(t *testing.T) {
+	type text string
+	type hover int
+
+	ng := New(WidgetFunc(func(ctx Context) *Node { return Get(`<div>`) }),
+		Transient[hover](), HistorySize(2))
+	defer ng.Close()
+	ng.mx.Lock()
+	defer ng.mx.Unlock()
+
+	state := func() (text, hover) {
+		ctx := Context{vx: ng.ctx}
+		return ValueOf[text](ctx), ValueOf[hover](ctx)
+	}
+
+	ng.turncrank(LoadContext(text("a")))
+	for _, v := range []any{text("b"), hover(1), text("c"), text("d"), hover(2)} {
+		ng.turncrank(LoadContext(v))
+	}
+
+	if txt, hv := state(); txt != "d" || hv != 2 {
+		t.Fatalf("invalid state %q %d", txt, hv)
+	}
+	ng.turncrank(Undo)
+	ng.turncrank(Undo)
+	if txt, hv := state(); txt != "b" || hv != 2 {
+		t.Errorf("after undo: got %q %d, want b 2", txt, hv)
+	}
+	if ng.turncrank(Undo) != nil {
+		t.Errorf("history must be limited to 2 states")
+	}
+
+	ng.turncrank(Redo)
+	if txt, _ := state(); txt != "c" {
+		t.Errorf("after redo: got %q, want c", txt)
+	}
+
+	ng.turncrank(LoadContext(text("e")))
+	if ng.turncrank(Redo) != nil {
+		t.Errorf("a new change must clear the redo states")
+	}
+	ng.turncrank(Undo)
+	if txt, _ := state(); txt != "c" {
+		t.Errorf("undo after new change: got %q, want c", txt)
+	}
+}
+
+func TestHistoryTurns(t *testing.T) {
+	type text string
+	hover := NewKey[int]("hover")
+
+	ng := newEngine(WidgetFunc(func(ctx Context) *Node { return Get(`<div>`) }), Transient(hover))
+	ng.Actions = make(chan Action, 1)
+	state := func() (text, int) {
+		ctx := Context{vx: ng.ctx}
+		return ValueOf[text](ctx), ValueOf(ctx, hover)
+	}
+
+	ng.turncrank(ng.batch(LoadContext(text("a"))))
+	ng.turncrank(ng.batch(Set(text("b"))))
+	ng.turncrank(ng.batch(Set(text("b"))))
+	ng.turncrank(ng.batch(Set(1, hover)))
+	if len(ng.past) != 1 {
+		t.Errorf("equal or transient values recorded: %d states", len(ng.past))
+	}
+
+	ng.turncrank(ng.batch(Chain(Undo, func(ctx Context) Context { Abort(errors.New("cancelled")); return ctx })))
+	if txt, _ := state(); txt != "b" || len(ng.past) != 1 || len(ng.future) != 0 {
+		t.Errorf("aborted undo moved the history: %q, %d past, %d future", txt, len(ng.past), len(ng.future))
+	}
+
+	ng.Actions <- Set(text("c"))
+	ng.turncrank(ng.batch(Undo))
+	if txt, hv := state(); txt != "c" || hv != 1 {
+		t.Errorf("after undo and change: got %q %d, want c 1", txt, hv)
+	}
+	ng.turncrank(ng.batch(Undo))
+	if txt, _ := state(); txt != "a" || len(ng.future) != 1 {
+		t.Errorf("change batched with undo not recorded: got %q, %d future", txt, len(ng.future))
+	}
+}
+
+func TestChangedValues(t *testing.T) {
+	type a int
+	type b int
+	type c int
+
+	ctx := WithValues(Context{}, a(1), b(2))
+	next := WithValues(ctx, b(2), c(3))
+
+	var got []any
+	for k := range ctx.vx.changed(next.vx) {
+		got = append(got, k)
+	}
+	if len(got) != 2 || got[0] == got[1] || slices.Contains(got, any(reflect.TypeFor[a]())) {
+		t.Errorf("changed keys: got %v, want b and c", got)
+	}
+	for range ctx.vx.changed(ctx.vx) {
+		t.Errorf("identical contexts have no change")
+	}
+}
+
+func TestCanUndoInBuild(t *testing.T) {
+	type text string
+
+	var undo, redo bool
+	ng := newEngine(WidgetFunc(func(ctx Context) *Node {
+		undo, redo = CanUndo(ctx), CanRedo(ctx)
+		return Get(`<div>`)
+	}))
+	ng.Actions = make(chan Action, 1)
+
+	ng.turncrank(ng.batch(LoadContext(text("a"))))
+	if undo || redo {
+		t.Errorf("first rendering: got undo=%t redo=%t, want none", undo, redo)
+	}
+	ng.turncrank(ng.batch(Set(text("b"))))
+	if !undo || redo {
+		t.Errorf("after change: got undo=%t redo=%t, want undo", undo, redo)
+	}
+	ng.turncrank(ng.batch(Undo))
+	if undo || !redo {
+		t.Errorf("after undo: got undo=%t redo=%t, want redo", undo, redo)
+	}
+	ng.turncrank(ng.batch(Redo))
+	if !undo || redo {
+		t.Errorf("after redo: got undo=%t redo=%t, want undo", undo, redo)
+	}
+}
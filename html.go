@@ -0,0 +1,158 @@
+package rx
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ToHTML creates a textual representation of the node tree.
+// This is useful for server-side rendering.
+// As such, there is no way to attach a callback to an entity.
+//
+// See [Node.WriteHTML] for the serialization rules; the output stops at the first error.
+func (n *Node) ToHTML() string {
+	var buf strings.Builder
+	n.WriteHTML(&buf)
+	return buf.String()
+}
+
+// WriteHTML writes the node tree to w, following the HTML serialization algorithm:
+//
+//   - text and attribute values are escaped, except in raw text elements (script, style, …), which must not contain their end tag (this is an error, as it would inject markup);
+//   - void elements (input, br, …) have no end tag, and their children are ignored;
+//   - attributes with an empty value are written as boolean attributes;
+//   - empty SVG elements are self-closing.
+//
+// Entities are written as the id of the element, as in the browser.
+// Properties (see [Node.SetProp]) are written as the initial value of the attribute of the same name.
+func (n *Node) WriteHTML(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if err := writeHTML(bw, n, false); err != nil {
+		bw.Flush()
+		return err
+	}
+	return bw.Flush()
+}
+
+// writeHTML serializes the node n.
+// Errors of the writer are kept in it, see [bufio.Writer]; only invalid trees are reported.
+func writeHTML(w *bufio.Writer, n *Node, svg bool) error {
+	if n == nil {
+		return errors.New("rx: nil node in HTML tree")
+	}
+	// skip nothing node, portals are rendered in place
+	if n.IsNothing() || n.TagName == "portal" {
+		for _, c := range n.Children {
+			if err := writeHTML(w, c, svg); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	svg = svg || n.TagName == "svg"
+
+	w.WriteString("<" + n.TagName)
+	if n.Entity != 0 {
+		writeAttr(w, "id", strconv.FormatUint(uint64(n.Entity), 10))
+	}
+	if n.Classes != "" {
+		writeAttr(w, "class", n.Classes)
+	}
+	for _, a := range n.Attrs {
+		if a.Name == "style" || slices.ContainsFunc(n.Props, func(p Prop) bool { return p.Name == a.Name }) {
+			continue // written below
+		}
+		writeAttr(w, a.Name, a.Value)
+	}
+	if decls := n.style(); len(decls) > 0 {
+		writeAttr(w, "style", formatStyle(decls))
+	}
+
+	text := n.Text
+	for _, p := range n.Props {
+		switch v := p.Value.(type) {
+		case nil:
+		case bool:
+			if v {
+				writeAttr(w, p.Name, "")
+			}
+		case string:
+			if n.TagName == "textarea" && p.Name == "value" {
+				text = v // a textarea has no value attribute
+				continue
+			}
+			writeAttr(w, p.Name, v)
+		default:
+			writeAttr(w, p.Name, fmt.Sprint(v))
+		}
+	}
+
+	switch {
+	case svg && text == "" && len(n.Children) == 0:
+		w.WriteString("/>")
+		return nil
+	case !svg && voidElements[n.TagName]:
+		w.WriteString(">")
+		return nil
+	}
+	w.WriteString(">")
+
+	switch {
+	case svg:
+		htmlEscaper.WriteString(w, text)
+	case rawTextElements[n.TagName]:
+		if strings.Contains(strings.ToLower(text), "</"+strings.ToLower(n.TagName)) {
+			return fmt.Errorf("rx: text of %s element contains its end tag: %q", n.TagName, text)
+		}
+		w.WriteString(text)
+	default:
+		if strings.HasPrefix(text, "\n") && (n.TagName == "pre" || n.TagName == "textarea" || n.TagName == "listing") {
+			w.WriteString("\n") // the parser drops the first newline of these elements
+		}
+		htmlEscaper.WriteString(w, text)
+	}
+
+	for _, c := range n.Children {
+		if err := writeHTML(w, c, svg && !strings.EqualFold(n.TagName, "foreignObject")); err != nil {
+			return err
+		}
+	}
+	w.WriteString("</" + n.TagName + ">")
+	return nil
+}
+
+func writeAttr(w *bufio.Writer, name, value string) {
+	w.WriteString(" " + name)
+	if value == "" {
+		return
+	}
+	w.WriteString(`="`)
+	attrEscaper.WriteString(w, value)
+	w.WriteString(`"`)
+}
+
+var (
+	htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\u00a0", "&nbsp;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", `"`, "&quot;", "<", "&lt;", ">", "&gt;", "\u00a0", "&nbsp;")
+)
+
+// voidElements cannot have content, and are written without end tag.
+// See https://html.spec.whatwg.org/multipage/syntax.html#void-elements
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+	// legacy elements, serialized the same way
+	"basefont": true, "bgsound": true, "frame": true, "keygen": true, "param": true,
+}
+
+// rawTextElements contain text which is not escaped.
+// See https://html.spec.whatwg.org/multipage/parsing.html#serialising-html-fragments
+var rawTextElements = map[string]bool{
+	"style": true, "script": true, "xmp": true, "iframe": true, "noembed": true, "noframes": true, "plaintext": true,
+}
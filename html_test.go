@@ -0,0 +1,105 @@
+package rx
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestToHTML(t *testing.T) {
+	cases := []struct {
+		name string
+		node func() *Node
+	}{
+		{"escape", func() *Node {
+			return Get(`<p>`).AddAttr("title", `say "hi" & <bye>`).SetText("<script>alert(1)</script> & co")
+		}},
+		{"attributes", func() *Node {
+			return Get(`<div class="flex gap-2" role="list">`).AddAttr("data-id", "3").AddBoolAttr("hidden", true)
+		}},
+		{"void", func() *Node {
+			return Get(`<form>`).AddChildren(
+				Get(`<label>Name</label>`),
+				Get(`<input type="text" required>`),
+				Get(`<br>`),
+				Get(`<img src="a.png" alt="">`),
+			)
+		}},
+		{"raw text", func() *Node {
+			return Get(`<div>`).AddChildren(
+				getNode("style").SetText("p > a { color: red; }"),
+				getNode("script").SetText(`if (a < b && c) { go("<p>") }`),
+			)
+		}},
+		{"svg", func() *Node {
+			return Get(`<svg viewbox="0 0 10 10">`).AddChildren(
+				Get(`<path d="M0 0L10 10">`),
+				getNode("text").SetText("a < b"),
+				Get(`<foreignobject>`).AddChildren(Get(`<input>`)),
+			)
+		}},
+		{"nothing", func() *Node {
+			return Get(`<ul>`).AddChildren(Nothing(Get(`<li>1</li>`), Nothing()), Get(`<li>2</li>`))
+		}},
+		{"properties and styles", func() *Node {
+			return Get(`<form style="color: red">`).SetStyle("--gap", "4px").AddChildren(
+				Get(`<input type="checkbox" checked>`).SetProp("checked", false),
+				Get(`<input value="initial">`).SetProp("value", "typed").SetProp("disabled", true),
+				Get(`<textarea>`).SetProp("value", "\nnew line"),
+			)
+		}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := c.node().ToHTML()
+
+			golden := filepath.Join("testdata", "html", filepath.Base(t.Name())+".html")
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("%s: %s", golden, cmp.Diff(string(want), got))
+			}
+		})
+	}
+}
+
+func TestToHTMLRawTextEnd(t *testing.T) {
+	for _, text := range []string{`</script><img src=x onerror=alert(1)>`, `a </SCRIPT >`, `"</scRipt"`} {
+		var buf strings.Builder
+		err := getNode("script").SetText(text).WriteHTML(&buf)
+		switch {
+		case err == nil:
+			t.Errorf("%q: end tag written in raw text", text)
+		case !strings.Contains(err.Error(), "contains its end tag"):
+			t.Errorf("%q: bad error message: %s", text, err)
+		case strings.Contains(buf.String(), text):
+			t.Errorf("%q: raw text written: %s", text, buf.String())
+		}
+	}
+
+	n := Get(`<div>`)
+	n.Children = append(n.Children, nil)
+	if err := n.WriteHTML(io.Discard); err == nil {
+		t.Errorf("nil node written")
+	}
+
+	// other end tags are fine
+	if got := getNode("style").SetText("a::after { content: '</p>' }").ToHTML(); !strings.Contains(got, "</p>") {
+		t.Errorf("style text altered: %s", got)
+	}
+}
@@ -0,0 +1,79 @@
+package rx
+
+import (
+	"slices"
+	"sync"
+)
+
+// registered intents, numbered after the built-in ones
+var intents struct {
+	mx     sync.Mutex
+	byName map[string]IntentType
+	specs  []intentSpec
+}
+
+// intentSpec describes to the JS shim how to raise a registered intent
+type intentSpec struct {
+	event     string
+	registers []string
+}
+
+// RegisterIntent returns a new intent type, raised by the JS shim when the DOM event of that name fires in the view:
+//
+//	var Input = rx.RegisterIntent("input", "target.value")
+//
+//	rx.Get(`<input>`).OnIntent(Input, func(ctx rx.Context) rx.Context {
+//		return rx.WithValue(ctx, Search(rx.R1(ctx)))
+//	})
+//
+// The intent targets the closest entity of the event target.
+// registers are paths to properties of the event, copied in the registers of the [CallFrame] (up to 4).
+// A name matching no DOM event can still be raised by subclasses of the shim, with passEvent(this.intents[name], …).
+//
+// Registering a name again returns the same intent type; the registers must be the same.
+// The intent type is printed as the name of the event, e.g. in logs.
+// The shim learns the registered intents when it starts, so they must be registered before the engine is created
+// (usually when declaring a package variable).
+func RegisterIntent(event string, registers ...string) IntentType {
+	intents.mx.Lock()
+	defer intents.mx.Unlock()
+
+	if it, ok := intents.byName[event]; ok {
+		spec := intents.specs[it-Seppuku-1]
+		assert(slices.Equal(spec.registers, registers), "intent %s registered again with registers %v, was %v", event, registers, spec.registers)
+		return it
+	}
+	if intents.byName == nil {
+		intents.byName = make(map[string]IntentType)
+	}
+	assert(len(registers) <= 4, "intent %s: at most 4 registers can be extracted, got %d", event, len(registers))
+
+	it := Seppuku + 1 + IntentType(len(intents.specs))
+	intents.byName[event] = it
+	intents.specs = append(intents.specs, intentSpec{event: event, registers: slices.Clone(registers)})
+	return it
+}
+
+// intentName returns the name of a registered intent, used by [IntentType.String] for the intents it does not know.
+func intentName(it IntentType, fallback string) string {
+	intents.mx.Lock()
+	defer intents.mx.Unlock()
+
+	if i := int(it - Seppuku - 1); i >= 0 && i < len(intents.specs) {
+		return intents.specs[i].event
+	}
+	return fallback
+}
+
+// registeredIntents iterates over the intents registered with [RegisterIntent]
+func registeredIntents(yield func(IntentType, intentSpec) bool) {
+	intents.mx.Lock()
+	specs := intents.specs
+	intents.mx.Unlock()
+
+	for i, s := range specs {
+		if !yield(Seppuku+1+IntentType(i), s) {
+			return
+		}
+	}
+}
@@ -34,8 +34,9 @@ const _IntentType_name = "NoIntentClickDoubleClickDragStartDragOverDragEndDropEs
 var _IntentType_index = [...]uint8{0, 8, 13, 24, 33, 41, 48, 52, 60, 66, 72, 78, 83, 87, 97, 111, 124, 138, 144, 151}
 
 func (i IntentType) String() string {
-	if i < 0 || i >= IntentType(len(_IntentType_index)-1) {
-		return "IntentType(" + strconv.FormatInt(int64(i), 10) + ")"
+	idx := int(i) - 0
+	if i < 0 || idx >= len(_IntentType_index)-1 {
+		return intentName(i, "IntentType("+strconv.FormatInt(int64(i), 10)+")")
 	}
-	return _IntentType_name[_IntentType_index[i]:_IntentType_index[i+1]]
+	return _IntentType_name[_IntentType_index[idx]:_IntentType_index[idx+1]]
 }
@@ -11,5 +11,15 @@ export var OpType;
     OpType[OpType["OpReuse"] = 6] = "OpReuse";
     OpType[OpType["OpReID"] = 7] = "OpReID";
     OpType[OpType["OpNext"] = 8] = "OpNext";
+    OpType[OpType["OpPatch"] = 9] = "OpPatch";
+    OpType[OpType["OpEnter"] = 10] = "OpEnter";
+    OpType[OpType["OpSkip"] = 11] = "OpSkip";
+    OpType[OpType["OpRemoveAttr"] = 12] = "OpRemoveAttr";
+    OpType[OpType["OpSetText"] = 13] = "OpSetText";
+    OpType[OpType["OpMove"] = 14] = "OpMove";
+    OpType[OpType["OpRemove"] = 15] = "OpRemove";
+    OpType[OpType["OpSetProp"] = 16] = "OpSetProp";
+    OpType[OpType["OpSetStyle"] = 17] = "OpSetStyle";
+    OpType[OpType["OpPortal"] = 18] = "OpPortal";
 })(OpType || (OpType = {}));
 //# sourceMappingURL=optype_abi.js.map
\ No newline at end of file
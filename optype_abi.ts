@@ -11,4 +11,14 @@ export enum OpType {
 	OpReuse= 6,
 	OpReID= 7,
 	OpNext= 8,
+	OpPatch= 9,
+	OpEnter= 10,
+	OpSkip= 11,
+	OpRemoveAttr= 12,
+	OpSetText= 13,
+	OpMove= 14,
+	OpRemove= 15,
+	OpSetProp= 16,
+	OpSetStyle= 17,
+	OpPortal= 18,
 }
\ No newline at end of file
@@ -0,0 +1,142 @@
+package rx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+)
+
+// Storage is a durable key-value store, used to persist values of the context.
+// Load returns an error matching [fs.ErrNotExist] if nothing is stored at key.
+type Storage interface {
+	Load(key string) ([]byte, error)
+	Store(key string, data []byte) error
+}
+
+// MemoryStorage keeps the values in memory, e.g. for tests.
+// The zero value is ready to use.
+type MemoryStorage struct {
+	mx sync.Mutex
+	kv map[string][]byte
+}
+
+func (s *MemoryStorage) Load(key string) ([]byte, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	data, ok := s.kv[key]
+	if !ok {
+		return nil, fmt.Errorf("loading %s: %w", key, fs.ErrNotExist)
+	}
+	return data, nil
+}
+
+func (s *MemoryStorage) Store(key string, data []byte) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.kv == nil {
+		s.kv = make(map[string][]byte)
+	}
+	s.kv[key] = data
+	return nil
+}
+
+// UseStorage sets the storage used by [Persist].
+// Without it, values are stored in the browser local storage, or in memory on other platforms.
+// It must be registered before the persisted types:
+//
+//	ng := rx.New(root, rx.UseStorage(rx.FileStorage(dir)), rx.Persist[Filters]("filters"))
+func UseStorage(st Storage) Action {
+	return func(ctx Context) Context {
+		ctx.ng.storage = st
+		return ctx
+	}
+}
+
+// persister saves values of one type in the storage
+type persister struct {
+	key     string
+	typ     any // type of the value, or [Key]
+	version int
+}
+
+// envelope is the stored representation of a value
+type envelope struct {
+	Version int             `json:"version"`
+	Value   json.RawMessage `json:"value"`
+}
+
+// Persist registers values of type T, or at the context key if given, to be saved under key after each turn where they change.
+// The stored value, if any, is loaded in the context when the engine is created:
+//
+//	ng := rx.New(root, rx.Persist[FilterSettings]("filters"))
+//
+// Values are encoded with [encoding/json].
+func Persist[T any](key string, at ...*Key[T]) Action { return PersistVersion(key, 0, nil, at...) }
+
+// PersistVersion is like [Persist], but tags the stored values with version.
+// Values stored with another version are converted with migrate, or ignored if migrate is nil.
+func PersistVersion[T any](key string, version int, migrate func(from int, data []byte) (T, error), at ...*Key[T]) Action {
+	return func(ctx Context) Context {
+		ng := ctx.ng
+		if ng.storage == nil {
+			ng.storage = defaultStorage()
+		}
+		ng.persisters = append(ng.persisters, persister{key: key, typ: keyOf(at), version: version})
+
+		data, err := ng.storage.Load(key)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			return ctx
+		case err != nil:
+			ng.logger.Warn("cannot load persisted value", "key", key, "error", err)
+			return ctx
+		}
+
+		var env envelope
+		var val T
+		switch err := json.Unmarshal(data, &env); {
+		case err != nil:
+			ng.logger.Warn("invalid persisted value", "key", key, "error", err)
+			return ctx
+		case env.Version == version:
+			err = json.Unmarshal(env.Value, &val)
+		case migrate == nil:
+			ng.logger.Info("dropping persisted value of another version", "key", key, "version", env.Version)
+			return ctx
+		default:
+			val, err = migrate(env.Version, env.Value)
+		}
+		if err != nil {
+			ng.logger.Warn("cannot restore persisted value", "key", key, "version", env.Version, "error", err)
+			return ctx
+		}
+		return WithValue(ctx, val, at...)
+	}
+}
+
+// persist saves the values changed between the old and cur states
+func (ng *Engine) persist(old, cur *vctx) {
+	for _, p := range ng.persisters {
+		l := cur.leaf(p.typ)
+		if l == nil || l == old.leaf(p.typ) {
+			continue
+		}
+		if err := p.save(ng.storage, l.val); err != nil {
+			ng.logger.Warn("cannot persist value", "key", p.key, "error", err)
+		}
+	}
+}
+
+func (p persister) save(st Storage, val any) error {
+	raw, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(envelope{Version: p.version, Value: raw})
+	if err != nil {
+		return err
+	}
+	return st.Store(p.key, data)
+}
@@ -0,0 +1,78 @@
+//go:build !js
+
+package rx
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+)
+
+func TestPersist(t *testing.T) {
+	type Filters struct{ Query string }
+	root := WidgetFunc(func(ctx Context) *Node { return Get(`<div>`) })
+
+	for _, st := range []Storage{new(MemoryStorage), FileStorage(t.TempDir())} {
+		ng := New(root, UseStorage(st), Persist[Filters]("filters"))
+		if _, _, err := ng.Dispatch(CallFrame{}); err != nil {
+			t.Fatal(err)
+		}
+		ng.mx.Lock()
+		ng.turncrank(LoadContext(Filters{Query: "status:open"}))
+		ng.mx.Unlock()
+		ng.Close()
+
+		ng = New(root, UseStorage(st), Persist[Filters]("filters"))
+		if got := ValueOf[Filters](Context{vx: ng.ctx}); got.Query != "status:open" {
+			t.Errorf("%T: value not restored, got %+v", st, got)
+		}
+		ng.Close()
+	}
+}
+
+func TestPersistMigration(t *testing.T) {
+	type Zoom float64
+	st := new(MemoryStorage)
+	st.Store("zoom", []byte(`{"version": 1, "value": "150%"}`))
+
+	migrate := func(from int, data []byte) (Zoom, error) {
+		var pct string
+		if err := json.Unmarshal(data, &pct); err != nil {
+			return 0, err
+		}
+		v, err := strconv.Atoi(pct[:len(pct)-1])
+		return Zoom(v) / 100, err
+	}
+
+	ng := New(nil, UseStorage(st), PersistVersion("zoom", 2, migrate))
+	defer ng.Close()
+	if got := ValueOf[Zoom](Context{vx: ng.ctx}); got != 1.5 {
+		t.Errorf("migrated value: got %v, want 1.5", got)
+	}
+
+	ng2 := New(nil, UseStorage(st), Persist[Zoom]("zoom"))
+	defer ng2.Close()
+	if got := ValueOf[Zoom](Context{vx: ng2.ctx}); got != 0 {
+		t.Errorf("value of another version must be ignored, got %v", got)
+	}
+}
+
+func TestPersistKey(t *testing.T) {
+	st := new(MemoryStorage)
+	width := NewKey[int]("width")
+	root := WidgetFunc(func(ctx Context) *Node { return Get(`<div>`) })
+
+	ng := New(root, UseStorage(st), Persist("width", width))
+	ng.mx.Lock()
+	ng.turncrank(DoNothing)
+	ng.turncrank(Set(240, width))
+	ng.turncrank(Set(3)) // another value of the same type
+	ng.mx.Unlock()
+	ng.Close()
+
+	ng = New(root, UseStorage(st), Persist("width", width))
+	defer ng.Close()
+	if got := ValueOf(Context{vx: ng.ctx}, width); got != 240 {
+		t.Errorf("value at key: got %d, want 240", got)
+	}
+}
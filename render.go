@@ -2,7 +2,7 @@ package rx
 
 import (
 	"encoding/binary"
-	"fmt"
+	"encoding/json"
 	"slices"
 	"strconv"
 	"strings"
@@ -17,11 +17,14 @@ type Node struct {
 	Focused  bool
 	Children []*Node
 	Attrs    []Attr // for arbitrary HTML elements
+	Props    []Prop // for live DOM properties, see [Node.SetProp]
+	Styles   []Attr // inline style declarations, see [Node.SetStyle]
 
 	visited bool
 
-	old Entity // for reuse nodes
-	hdl intentHandler
+	old    Entity // for reuse nodes
+	target string // for portal nodes
+	hdl    intentHandler
 }
 
 func (n *Node) SetText(text string) *Node { n.Text = text; return n }
@@ -45,6 +48,34 @@ func (n *Node) AddAttr(kv ...string) *Node {
 	return n
 }
 
+// SetProp adds or replace a property of the DOM element, e.g. the value of an input.
+// Unlike attributes, which only hold the initial state, properties reflect the live state of form elements:
+// they are assigned on each rendering, so the element follows the context even after the user interacted with it.
+//
+//	rx.Get(`<input type="checkbox">`).SetProp("checked", done)
+//
+// Values must be encodable to JSON, usually a string, a boolean or a number.
+// Properties are not removed from the element when they are no longer set.
+func (n *Node) SetProp(name string, value any) *Node {
+	idx := slices.IndexFunc(n.Props, func(p Prop) bool { return p.Name == name })
+	if idx == -1 {
+		n.Props = append(n.Props, Prop{Name: name, Value: value})
+	} else {
+		n.Props[idx].Value = value
+	}
+	return n
+}
+
+// GetProp returns the value set for the property, or nil.
+func (n *Node) GetProp(name string) any {
+	for _, p := range n.Props {
+		if p.Name == name {
+			return p.Value
+		}
+	}
+	return nil
+}
+
 // GetAttr returns the value set for the attribute.
 // An empty string is returned if no value is set.
 func (n *Node) GetAttr(attr string) string {
@@ -66,7 +97,7 @@ func (n *Node) OnIntent(evt IntentType, h Action) *Node {
 		return n
 	}
 
-	n.hdl[evt] = h
+	n.hdl.set(evt, h)
 	return n
 }
 
@@ -130,7 +161,7 @@ func (n *Node) ElementID(ctx Context) string {
 
 // ActionFor returns the action registered in n for event of type t.
 // This is mostly useful for tests.
-func ActionFor(n *Node, t IntentType) Action { return n.hdl[t] }
+func ActionFor(n *Node, t IntentType) Action { return n.hdl.get(t) }
 
 // Visit is an internal function used to ensure there are no cycle during rendering.
 func (n *Node) Visit() {
@@ -154,8 +185,46 @@ func (n *Node) Visit() {
 //  2. Children of Nothing nodes will become children of the parent of the Nothing node.
 func Nothing(ws ...*Node) *Node { return getNode("nothing").AddChildren(ws...) }
 
+// Portal returns a node rendering its children in a mount point, instead of at its position in the view.
+// This is used for modals, tooltips or dropdowns, which must not be clipped by their ancestors:
+//
+//	rx.Portal("overlay", rx.Get(`<div class="modal">`).AddChildren(…))
+//
+// The target names either a layer on top of the view (created as needed), or an element of the host page when prefixed by "#".
+// Children are still handled as if they were at the position of the portal:
+// their intents reach the handlers of the parents of the portal
+// (the elements at the top of the portal are given an entity for this).
+// Opening or closing a portal only patches its mount point, the rest of the view is left in place.
+//
+// Several portals can share a target, their children are then rendered in order.
+func Portal(target string, children ...*Node) *Node {
+	n := getNode("portal").AddChildren(children...)
+	n.target = target
+	return n
+}
+
 type Attr struct{ Name, Value string }
 
+// Prop is a property of a DOM element, see [Node.SetProp].
+type Prop struct {
+	Name  string
+	Value any
+}
+
+// encodeProps returns the properties as attributes holding their JSON value, as sent to the browser.
+func encodeProps(props []Prop) []Attr {
+	if len(props) == 0 {
+		return nil
+	}
+	enc := make([]Attr, len(props))
+	for i, p := range props {
+		v, err := json.Marshal(p.Value)
+		assert(err == nil, "invalid value for property %s: %v", p.Name, err)
+		enc[i] = Attr{Name: p.Name, Value: string(v)}
+	}
+	return enc
+}
+
 type poolNode struct {
 	next  *poolNode
 	nodes []Node
@@ -192,10 +261,22 @@ func getNode(tagname string) *Node {
 
 	last := &pool.nodes[len(pool.nodes)-1]
 	// reset all fields, preserve space already alloc for values
-	*last = Node{TagName: tagname, Attrs: last.Attrs[:0], Children: last.Children[:0]}
+	*last = Node{TagName: tagname, Attrs: last.Attrs[:0], Props: last.Props[:0], Styles: last.Styles[:0], Children: last.Children[:0]}
 	return last
 }
 
+// poolLen returns the number of nodes allocated since the last [freePool].
+func poolLen() int {
+	npool.nmtx.Lock()
+	defer npool.nmtx.Unlock()
+
+	n := 0
+	for pool := &npool.poolNode; pool != nil; pool = pool.next {
+		n += len(pool.nodes)
+	}
+	return n
+}
+
 // freePool de-allocate all nodes at once.
 func freePool() {
 	npool.nmtx.Lock()
@@ -206,8 +287,9 @@ func freePool() {
 	npool.nodes = npool.nodes[:0]
 }
 
-// serialize does a preorder visit of the node tree, keeping track of nodes in the entity tree
-func serialize(n *Node, tree *etree, ctr *Counter, vm XAS) XAS {
+// serialize does a preorder visit of the node tree, keeping track of nodes in the entity tree,
+// and recording the elements in the retained tree.
+func serialize(n *Node, tree *etree, vt *vtree, ctr *Counter, vm XAS) XAS {
 	if n.visited {
 		panic("cycle detected")
 	}
@@ -220,7 +302,7 @@ func serialize(n *Node, tree *etree, ctr *Counter, vm XAS) XAS {
 	case "nothing":
 		for _, c := range n.Children {
 			assert(c != nil, "nil child in node: %v", n)
-			vm = serialize(c, tree, ctr, vm)
+			vm = serialize(c, tree, vt, ctr, vm)
 		}
 		return vm
 
@@ -228,14 +310,37 @@ func serialize(n *Node, tree *etree, ctr *Counter, vm XAS) XAS {
 		// Reuse ports the old tree to the new one
 		// ReID is then updating the ID, so that the handlers fire on the correct element
 		vm = vm.AddInstr(OpReuse, strconv.FormatUint(uint64(n.old), 10))
+		ren := make(map[Entity]Entity)
 		tree.reuse(n.old, n.Entity, ctr, func(from, to Entity) {
 			vm = vm.AddInstr(OpReID,
 				strconv.FormatUint(uint64(from), 10),
 				strconv.FormatUint(uint64(to), 10))
-
+			ren[from] = to
 		})
+		for _, p := range vt.graft(n.old, ren) {
+			// the content of portals stays in their mount point, and is rendered again
+			vm = vm.AddInstr(OpPortal, p.target)
+			for _, c := range p.children {
+				vm, _ = c.create(vm) // grafted children are not reused
+			}
+			vm = vm.AddInstr(OpNext)
+		}
 
 		return vm
+
+	case "portal":
+		// children are rendered in the mount point, but keep their parents in the entity tree
+		vm = vm.AddInstr(OpPortal, n.target)
+		vt.portal(n.target)
+		for _, c := range n.Children {
+			assert(c != nil, "nil child in node: %v", n)
+			if tree.parent() != -1 {
+				ownEntity(c, ctr)
+			}
+			vm = serialize(c, tree, vt, ctr, vm)
+		}
+		vt.close()
+		return vm.AddInstr(OpNext)
 	}
 	vm = vm.AddInstr(OpCreateElement, n.TagName)
 
@@ -256,66 +361,55 @@ func serialize(n *Node, tree *etree, ctr *Counter, vm XAS) XAS {
 		}
 		vm = vm.AddInstr(OpSetID, strconv.FormatUint(uint64(n.Entity), 10))
 	}
+	v := &vnode{tag: n.TagName, classes: n.Classes, text: n.Text, ntt: n.Entity,
+		attrs: withoutStyle(n.Attrs), styles: n.style(), props: encodeProps(n.Props)}
+	vt.open(v)
 
-	for _, a := range n.Attrs {
+	for _, a := range v.attrs {
 		vm = vm.AddInstr(OpSetAttr, a.Name, a.Value)
 	}
+	for _, d := range v.styles {
+		vm = vm.AddInstr(OpSetStyle, d.Name, d.Value)
+	}
 	if n.Text != "" {
 		vm = vm.AddInstr(OpAddText, n.Text)
 	}
 
 	for _, c := range n.Children {
-		vm = serialize(c, tree, ctr, vm)
+		vm = serialize(c, tree, vt, ctr, vm)
+	}
+	// properties are set once the children exist, e.g. the value of a select
+	for _, p := range v.props {
+		vm = vm.AddInstr(OpSetProp, p.Name, p.Value)
 	}
 	if n.Entity != 0 {
 		tree.closeScope(idx)
 	}
+	vt.close()
 
 	return vm.AddInstr(OpNext)
 }
 
-// Build bottoms-out the rendering tree: a node is a widget that is self
-func (n *Node) Build(_ Context) *Node { return n }
-
-// ToHTML creates a textual representation of the node tree.
-// This is useful for server-side rendering.
-// As such, there is no way to attach a callback to an entity.
-func (n *Node) ToHTML() string {
-	var buf strings.Builder
-	serializeHTML(n, &buf)
-	return buf.String()
-}
-
-func serializeHTML(n *Node, buf *strings.Builder) {
-	// skip nothing node
-	if n.IsNothing() {
+// ownEntity gives an entity to the elements at the top of a portal, held in an entity.
+// The browser finds the target of an intent by walking up the DOM, which stops at the mount point:
+// with an entity, the intents reach the parents of the portal in the entity tree.
+func ownEntity(n *Node, ctr *Counter) {
+	switch n.TagName {
+	case "nothing":
 		for _, c := range n.Children {
-			assert(c != nil, "nil child in node: %v", n)
-			serializeHTML(c, buf)
+			ownEntity(c, ctr)
+		}
+	case "reuse", "portal":
+	default:
+		if n.Entity == 0 {
+			n.Entity = ctr.Inc()
 		}
-		return
-	}
-
-	fmt.Fprintf(buf, "<%s ", n.TagName)
-	if len(n.Classes) > 0 {
-		fmt.Fprintf(buf, "class=\"%s\" ", n.Classes)
-	}
-
-	for _, a := range n.Attrs {
-		fmt.Fprintf(buf, "%s=\"%s\"", a.Name, a.Value)
-	}
-	fmt.Fprint(buf, ">")
-
-	if n.Text != "" {
-		fmt.Fprint(buf, n.Text)
-	}
-
-	for _, c := range n.Children {
-		serializeHTML(c, buf)
 	}
-	fmt.Fprintf(buf, "</%s>", n.TagName)
 }
 
+// Build bottoms-out the rendering tree: a node is a widget that is self
+func (n *Node) Build(_ Context) *Node { return n }
+
 // using an alias let's us run go generate but do not alter existing code
 //
 //go:generate go tool rxabi -type OpType
@@ -331,8 +425,59 @@ const (
 	OpReuse
 	OpReID
 	OpNext
+	OpPatch
+	OpEnter
+	OpSkip
+	OpRemoveAttr
+	OpSetText
+	OpMove
+	OpRemove
+	OpSetProp
+	OpSetStyle
+	OpPortal
 )
 
+// OpNames holds the name of each instruction, e.g. to disassemble programs.
+var OpNames = [...]string{
+	OpTerm:          "Term",
+	OpCreateElement: "CreateElement",
+	OpSetClass:      "SetClass",
+	OpSetID:         "SetID",
+	OpSetAttr:       "SetAttr",
+	OpAddText:       "AddText",
+	OpReuse:         "Reuse",
+	OpReID:          "ReID",
+	OpNext:          "Next",
+	OpPatch:         "Patch",
+	OpEnter:         "Enter",
+	OpSkip:          "Skip",
+	OpRemoveAttr:    "RemoveAttr",
+	OpSetText:       "SetText",
+	OpMove:          "Move",
+	OpRemove:        "Remove",
+	OpSetProp:       "SetProp",
+	OpSetStyle:      "SetStyle",
+	OpPortal:        "Portal",
+}
+
+// OpArity holds the number of string arguments taken by each instruction.
+var OpArity = [len(OpNames)]int{
+	OpCreateElement: 1,
+	OpSetClass:      1,
+	OpSetID:         1,
+	OpSetAttr:       2,
+	OpAddText:       1,
+	OpReuse:         1,
+	OpReID:          2,
+	OpSkip:          1,
+	OpRemoveAttr:    1,
+	OpSetText:       1,
+	OpMove:          1,
+	OpSetProp:       2,
+	OpSetStyle:      2,
+	OpPortal:        1,
+}
+
 type XAS []byte
 
 func (vm XAS) AddInstr(code byte, val ...string) XAS {
@@ -18,4 +18,31 @@ func TestRender(t *testing.T) {
 			t.Fatalf("n1 and n2 pointers are equal")
 		}
 	})
+
+	t.Run("copied node has its own handlers", func(t *testing.T) {
+		type clicked string
+		set := func(v clicked) Action { return func(ctx Context) Context { return WithValue(ctx, v) } }
+
+		n1 := Get(`<button>`).OnIntent(Click, set("original"))
+		n2 := *n1
+		n2.OnIntent(Click, set("copy"))
+		if got := ValueOf[clicked](ActionFor(n1, Click)(Context{})); got != "original" {
+			t.Errorf("handler of the original node replaced by %q", got)
+		}
+	})
+}
+
+func TestStyle(t *testing.T) {
+	n := Get(`<div style="color: red; background: url('a;b.png'); --Gap: 2px">`).
+		SetStyle("Color", "blue").
+		SetStyle("background", "").
+		SetStyle("width", "10px")
+
+	want := "color: blue; --Gap: 2px; width: 10px;"
+	if got := formatStyle(n.style()); got != want {
+		t.Errorf("merged style: got %q, want %q", got, want)
+	}
+	if got := n.GetStyle("--Gap"); got != "2px" {
+		t.Errorf("custom property: got %q", got)
+	}
 }
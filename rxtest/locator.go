@@ -2,6 +2,8 @@
 package rxtest
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"slices"
@@ -254,9 +256,38 @@ func ByTestID(testid string) Matcher {
 	}
 }
 
+// HasProp finds a node whose DOM property is set to value (see [rx.Node.SetProp]).
+// Values are compared as they are sent to the browser, encoded in JSON:
+// slices and maps are compared by content, and numbers of different types can match.
+func HasProp(name string, value any) Matcher {
+	want, err := json.Marshal(value)
+	return func(n *rx.Node) bool {
+		if err != nil {
+			return false
+		}
+		got, err := json.Marshal(n.GetProp(name))
+		return err == nil && bytes.Equal(got, want)
+	}
+}
+
+// HasValue finds a form element with the given value.
+// The value property takes precedence over the attribute, as in the browser.
+func HasValue(value string) Matcher {
+	return func(n *rx.Node) bool {
+		if v, ok := n.GetProp("value").(string); ok {
+			return v == value
+		}
+		return hasAttr(n, "value") && n.GetAttr("value") == value
+	}
+}
+
 // IsChecked returns a matcher that checks if a checkbox/radio is checked.
 func IsChecked() Matcher {
 	return func(n *rx.Node) bool {
+		// The checked property reflects the live state, and takes precedence
+		if c, ok := n.GetProp("checked").(bool); ok {
+			return c
+		}
 		// Check for checked attribute (HTML boolean attribute)
 		if hasAttr(n, "checked") {
 			return true
@@ -32,3 +32,28 @@ func TestByTestID(t *testing.T) {
 		t.Errorf("expected not to find node with testid 'nonexistent'")
 	}
 }
+
+func TestProps(t *testing.T) {
+	n := rx.Get("<form>").AddChildren(
+		rx.Get(`<input type="checkbox" checked>`).SetProp("checked", false),
+		rx.Get(`<input type="text" value="initial">`).SetProp("value", "typed"),
+	)
+
+	if r := Locate(Root(n), IsChecked()); r != notFound {
+		t.Errorf("checked property must take precedence over the attribute")
+	}
+	if r := Locate(Root(n), HasValue("typed")); r == notFound {
+		t.Errorf("expected to find input with value 'typed'")
+	}
+	if r := Locate(Root(n), HasProp("value", "initial")); r != notFound {
+		t.Errorf("expected the attribute not to be read as a property")
+	}
+
+	n = rx.Get("<select multiple>").SetProp("selected", []int{1, 3}).SetProp("size", 4)
+	if r := Locate(Root(n), HasProp("selected", []int{1, 3})); r == notFound {
+		t.Errorf("slice properties must be compared by content")
+	}
+	if r := Locate(Root(n), HasProp("size", 4.0)); r == notFound {
+		t.Errorf("numbers must be compared by value")
+	}
+}
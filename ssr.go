@@ -0,0 +1,80 @@
+//go:build !js
+
+package rx
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// RenderHTML renders the first view of the engine on the server, so the page is displayed before the WASM module runs.
+// The output is a declarative shadow root, to ship inside the rx-bootstrap element:
+//
+//	ng := rx.New(app, rx.LoadContext(initial))
+//	defer ng.Close()
+//
+//	io.WriteString(w, `<rx-bootstrap wasm-url="/app.wasm">`)
+//	ng.RenderHTML(w)
+//	io.WriteString(w, `</rx-bootstrap>`)
+//
+// The first turn of the client attaches to the rendered elements instead of creating them,
+// provided it builds the same view: the engine in the browser must start from the same context.
+// Since entities are numbered the same way on both sides, intents raised on the server-rendered elements reach their handlers.
+// If the views differ, the client logs a warning, and rebuilds the view from scratch.
+// Portals are not rendered on the server, so a view holding portals is always rebuilt.
+//
+// RenderHTML can only be called on a new engine, instead of its first turn.
+func (ng *Engine) RenderHTML(w io.Writer) (err error) {
+	ng.mx.Lock()
+	defer ng.mx.Unlock()
+	switch {
+	case ng.life.Err() != nil:
+		return ErrClosed
+	case ng.gen != 0:
+		return errors.New("rx: server-side rendering must be the first turn of the engine")
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("rendering on the server: %v", r)
+			}
+		}()
+		ng.turncrank(ng.transaction(ng.mount)) // failures are reported in ng.failure
+		ng.publish()
+	}()
+	if err != nil {
+		return err
+	}
+	if ng.failure != nil {
+		return fmt.Errorf("rendering on the server: %w", ng.failure)
+	}
+
+	// the retained tree holds exactly what the client builds on its first turn
+	bw := bufio.NewWriter(w)
+	bw.WriteString(`<template shadowrootmode="open">`)
+	for _, v := range ng.vt.v1 {
+		if err := writeHTML(bw, v.node(), false); err != nil {
+			return fmt.Errorf("rendering on the server: %w", err)
+		}
+	}
+	bw.WriteString(`</template>`)
+	return bw.Flush()
+}
+
+// node returns a copy of the retained element as a node, to serialize it as HTML.
+func (v *vnode) node() *Node {
+	n := &Node{TagName: v.tag, Classes: v.classes, Text: v.text, Entity: v.ntt, Attrs: v.attrs, Styles: v.styles}
+	for _, p := range v.props {
+		var val any
+		json.Unmarshal([]byte(p.Value), &val)
+		n.Props = append(n.Props, Prop{Name: p.Name, Value: val})
+	}
+	for _, c := range v.children {
+		n.Children = append(n.Children, c.node())
+	}
+	return n
+}
@@ -0,0 +1,106 @@
+//go:build !js
+
+package rx
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRenderHTML(t *testing.T) {
+	type picked string
+	app := WidgetFunc(func(ctx Context) *Node {
+		ul := Get(`<ul class="menu">`)
+		for _, item := range []string{"open", "save <as>"} {
+			ul.AddChildren(Get(`<li>`).SetText(item).OnIntent(Click, Set(picked(item))))
+		}
+		return Nothing(Get(`<h1>Menu</h1>`), ul)
+	})
+
+	server := New(app)
+	defer server.Close()
+	var html strings.Builder
+	if err := server.RenderHTML(&html); err != nil {
+		t.Fatal(err)
+	}
+	want := `<template shadowrootmode="open"><h1>Menu</h1><ul class="menu">` +
+		`<li id="2">open</li><li id="4">save &lt;as&gt;</li></ul></template>`
+	if html.String() != want {
+		t.Errorf("server view: %s", cmp.Diff(want, html.String()))
+	}
+	if err := server.RenderHTML(&html); err == nil {
+		t.Errorf("server-side rendering must be the first turn")
+	}
+
+	// the client numbers the entities the same way, so it can attach to the server view
+	client := New(app)
+	defer client.Close()
+	client.mx.Lock()
+	defer client.mx.Unlock()
+
+	var ids []string
+	for _, in := range disasm(client.turncrank(client.mount)) {
+		if id, ok := strings.CutPrefix(in, "SetID "); ok {
+			ids = append(ids, id)
+		}
+	}
+	var rendered []string
+	for _, m := range regexp.MustCompile(`id="(\d+)"`).FindAllStringSubmatch(html.String(), -1) {
+		rendered = append(rendered, m[1])
+	}
+	if !cmp.Equal(ids, rendered) {
+		t.Errorf("entities: %s", cmp.Diff(rendered, ids))
+	}
+
+	client.turncrank(client.intent(CallFrame{IntentType: Click, Entity: 4, Gen: client.gen}))
+	if got := ValueOf[picked](Context{vx: client.ctx}); got != "save <as>" {
+		t.Errorf("intent on server-rendered entity: got %q", got)
+	}
+}
+
+func TestRenderHTMLPortal(t *testing.T) {
+	app := WidgetFunc(func(ctx Context) *Node {
+		return Get(`<main>`).AddChildren(Get(`<h1>Editor</h1>`), Portal("overlay", Get(`<div class="modal">`)))
+	})
+
+	server := New(app)
+	defer server.Close()
+	var html strings.Builder
+	if err := server.RenderHTML(&html); err != nil {
+		t.Fatal(err)
+	}
+	if want := `<template shadowrootmode="open"><main><h1>Editor</h1></main></template>`; html.String() != want {
+		t.Errorf("portals must be left out: %s", cmp.Diff(want, html.String()))
+	}
+
+	// the first program of the client holds the portal, so the view is rebuilt instead of hydrated
+	client := New(app)
+	defer client.Close()
+	client.mx.Lock()
+	defer client.mx.Unlock()
+	if prog := disasm(client.turncrank(client.mount)); !slices.Contains(prog, "Portal overlay") {
+		t.Errorf("portal missing from the first program: %v", prog)
+	}
+}
+
+func TestRenderHTMLFailure(t *testing.T) {
+	app := WidgetFunc(func(ctx Context) *Node { return Get(`<h1>Menu</h1>`) })
+	load := errors.New("cannot load the menu")
+	server := New(app, Watch(func(old, cur WidgetFunc) Action {
+		return func(ctx Context) Context { Abort(load); return ctx }
+	}))
+	defer server.Close()
+
+	var html strings.Builder
+	if err := server.RenderHTML(&html); !errors.Is(err, load) {
+		t.Errorf("failing initial action: got %v, want %v", err, load)
+	}
+	if html.Len() > 0 {
+		t.Errorf("view written after a failure: %s", html.String())
+	}
+}
@@ -0,0 +1,48 @@
+package rx
+
+import "time"
+
+// TurnStats describes the work done during a turn of the engine.
+type TurnStats struct {
+	Gen     int        // generation rendered by the turn
+	Intent  IntentType // triggering intent, [NoIntent] for other actions
+	Skipped bool       // no rendering, the actions returned no context
+	Patch   bool       // the DOM was patched in place, instead of rebuilt
+
+	Build     time.Duration // Root.Build
+	Serialize time.Duration // serialization and diff of the node tree
+	Draw      time.Duration // execution of the program in the browser
+
+	Nodes    int // allocated from the pool
+	Entities int
+	XAS      int // size of the program, in bytes
+}
+
+// Stats returns the statistics of the last turn.
+func (ng *Engine) Stats() TurnStats {
+	ng.smx.Lock()
+	defer ng.smx.Unlock()
+	return ng.last
+}
+
+// OnTurn registers a function called with the statistics of each turn, once the view is drawn.
+// fn runs on the engine loop, and must not block.
+func (ng *Engine) OnTurn(fn func(TurnStats)) {
+	ng.smx.Lock()
+	defer ng.smx.Unlock()
+	ng.turnHooks = append(ng.turnHooks, fn)
+}
+
+// publish records the statistics of the current turn
+func (ng *Engine) publish() {
+	ng.turn.Draw, ng.drawtime = ng.drawtime, 0
+
+	ng.smx.Lock()
+	ng.last = ng.turn
+	hooks := ng.turnHooks
+	ng.smx.Unlock()
+
+	for _, fn := range hooks {
+		fn(ng.turn)
+	}
+}
@@ -0,0 +1,41 @@
+//go:build !js
+
+package rx
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+func defaultStorage() Storage { return new(MemoryStorage) }
+
+// FileStorage stores each value in a file of the directory.
+type FileStorage string
+
+func (dir FileStorage) path(key string) string {
+	return filepath.Join(string(dir), url.PathEscape(key)+".json")
+}
+
+func (dir FileStorage) Load(key string) ([]byte, error) { return os.ReadFile(dir.path(key)) }
+
+// Store replaces the file atomically, so a crash does not leave a partial value.
+func (dir FileStorage) Store(key string, data []byte) error {
+	if err := os.MkdirAll(string(dir), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(string(dir), ".rx-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), dir.path(key))
+}
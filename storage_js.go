@@ -0,0 +1,39 @@
+package rx
+
+import (
+	"fmt"
+	"io/fs"
+	"syscall/js"
+)
+
+func defaultStorage() Storage { return LocalStorage{Prefix: "rx."} }
+
+// LocalStorage stores values in the [localStorage] of the browser, under the prefixed key.
+//
+// [localStorage]: https://developer.mozilla.org/en-US/docs/Web/API/Window/localStorage
+type LocalStorage struct{ Prefix string }
+
+func (s LocalStorage) Load(key string) (data []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("loading %s: %v", key, r) // e.g. storage disabled by the user
+		}
+	}()
+
+	v := js.Global().Get("localStorage").Call("getItem", s.Prefix+key)
+	if v.IsNull() {
+		return nil, fmt.Errorf("loading %s: %w", key, fs.ErrNotExist)
+	}
+	return []byte(v.String()), nil
+}
+
+func (s LocalStorage) Store(key string, data []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("storing %s: %v", key, r) // e.g. quota exceeded
+		}
+	}()
+
+	js.Global().Get("localStorage").Call("setItem", s.Prefix+key, string(data))
+	return nil
+}
@@ -0,0 +1,125 @@
+package rx
+
+import (
+	"slices"
+	"strings"
+)
+
+// SetStyle sets a declaration of the inline style of the node, e.g. a computed width:
+//
+//	rx.Get(`<td>`).SetStyle("width", strconv.Itoa(w)+"px").SetStyle("--accent", color)
+//
+// Declarations are merged with the style attribute, if any, and take precedence over it.
+// An empty value removes the declaration.
+// When patching the view, only the declarations that changed are sent to the browser.
+func (n *Node) SetStyle(name, value string) *Node {
+	name = styleName(name)
+	idx := slices.IndexFunc(n.Styles, func(s Attr) bool { return s.Name == name })
+	if idx == -1 {
+		n.Styles = append(n.Styles, Attr{Name: name, Value: value})
+	} else {
+		n.Styles[idx].Value = value
+	}
+	return n
+}
+
+// GetStyle returns the value of the style declaration, merged as in [Node.SetStyle].
+// An empty string is returned if the declaration is not set.
+func (n *Node) GetStyle(name string) string {
+	name = styleName(name)
+	for _, s := range n.style() {
+		if s.Name == name {
+			return s.Value
+		}
+	}
+	return ""
+}
+
+// styleName normalizes the name of a property; custom properties are case-sensitive.
+func styleName(name string) string {
+	name = strings.TrimSpace(name)
+	if strings.HasPrefix(name, "--") {
+		return name
+	}
+	return strings.ToLower(name)
+}
+
+// style returns the declarations of the style attribute, updated with the ones set with [Node.SetStyle].
+func (n *Node) style() []Attr {
+	decls := parseStyle(n.GetAttr("style"))
+	for _, s := range n.Styles {
+		idx := slices.IndexFunc(decls, func(d Attr) bool { return d.Name == s.Name })
+		switch {
+		case s.Value == "" && idx != -1:
+			decls = slices.Delete(decls, idx, idx+1)
+		case s.Value == "":
+		case idx == -1:
+			decls = append(decls, s)
+		default:
+			decls[idx].Value = s.Value
+		}
+	}
+	return decls
+}
+
+// parseStyle splits the declarations of a style attribute.
+// Semicolons in quoted strings or parenthesis (e.g. an url) do not end the declaration.
+func parseStyle(style string) []Attr {
+	var decls []Attr
+	add := func(decl string) {
+		name, value, ok := strings.Cut(decl, ":")
+		name, value = styleName(name), strings.TrimSpace(value)
+		if !ok || name == "" || value == "" {
+			return
+		}
+		if idx := slices.IndexFunc(decls, func(d Attr) bool { return d.Name == name }); idx != -1 {
+			decls[idx].Value = value // last one wins, as in CSS
+			return
+		}
+		decls = append(decls, Attr{Name: name, Value: value})
+	}
+
+	var (
+		quote byte
+		depth int
+		start int
+	)
+	for i := 0; i < len(style); i++ {
+		switch c := style[i]; {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case c == ';' && depth == 0:
+			add(style[start:i])
+			start = i + 1
+		}
+	}
+	add(style[start:])
+	return decls
+}
+
+// formatStyle returns the declarations as the value of a style attribute
+func formatStyle(decls []Attr) string {
+	var buf strings.Builder
+	for i, d := range decls {
+		if i > 0 {
+			buf.WriteString(" ")
+		}
+		buf.WriteString(d.Name + ": " + d.Value + ";")
+	}
+	return buf.String()
+}
+
+// withoutStyle returns a copy of attrs, without the style attribute (sent as declarations instead)
+func withoutStyle(attrs []Attr) []Attr {
+	return slices.DeleteFunc(slices.Clone(attrs), func(a Attr) bool { return a.Name == "style" })
+}
@@ -0,0 +1,78 @@
+package rx
+
+import "fmt"
+
+// aborted is the panic value raised by [Abort]
+type aborted struct{ err error }
+
+// Abort stops the current action: the changes it made to the context are discarded,
+// and err is passed to the hooks registered with [OnError].
+//
+//	func save(ctx rx.Context) rx.Context {
+//		f := rx.ValueOf[Form](ctx)
+//		if err := f.Validate(); err != nil {
+//			rx.Abort(err)
+//		}
+//		…
+//	}
+func Abort(err error) { panic(aborted{err}) }
+
+// OnError registers a hook called when an action fails, by panicking or calling [Abort].
+// The action returned by the hook, if not nil, is applied to the context as it was before the failure,
+// e.g. to show the error to the user:
+//
+//	ng := rx.New(root, rx.OnError(func(err error) rx.Action { return rx.Set(LastError{err}) }))
+func OnError(hook func(error) Action) Action {
+	return func(ctx Context) Context {
+		ctx.ng.errHooks = append(ctx.ng.errHooks, hook)
+		return ctx
+	}
+}
+
+// transact applies act as a transaction: if it fails, the changes are discarded and the error hooks run on ctx.
+// noAction is returned if there is nothing to render.
+func (ng *Engine) transact(act Action, ctx Context) (out Context) {
+	moved := ng.moved
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		ng.moved = moved // history moves are part of the changes
+
+		var err error
+		switch r := r.(type) {
+		case caught:
+			out = ctx // the changes are discarded, but the fallback must be rendered
+			return
+		case aborted:
+			err = r.err
+		case error:
+			err = fmt.Errorf("rx: action panicked: %w", r)
+		default:
+			err = fmt.Errorf("rx: action panicked: %v", r)
+		}
+		ng.failure = err
+		ng.logger.Error("action failed, changes discarded", "error", err)
+
+		out = noAction
+		for _, hook := range ng.errHooks {
+			if act := hook(err); act != nil {
+				if c := act(ctx); c != noAction {
+					ctx, out = c, c
+				}
+			}
+		}
+	}()
+
+	out = act(ctx)
+	if ng.logdiffs {
+		ng.logDiff(ctx, out)
+	}
+	return out
+}
+
+// transaction returns act, applied with [Engine.transact]
+func (ng *Engine) transaction(act Action) Action {
+	return func(ctx Context) Context { return ng.transact(act, ctx) }
+}
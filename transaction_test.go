@@ -0,0 +1,63 @@
+package rx
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestTransactions(t *testing.T) {
+	type Name string
+	type Age int
+	type LastError struct{ error }
+	errInvalid := errors.New("invalid age")
+
+	ng := &Engine{Actions: make(chan Action, 2), logger: slog.New(newLogHandler())}
+	ctx := Chain(
+		LoadContext(Name("Doe"), Age(40)),
+		OnError(func(err error) Action { return Set(LastError{err}) }),
+	)(Context{ng: ng})
+
+	got := ng.transact(Mutate(
+		func(n *Name) { *n = "Bond" },
+		func(a *Age) { panic("unexpected") },
+	), ctx)
+	if ValueOf[Name](got) != "Doe" {
+		t.Errorf("partial mutation committed: %s", ValueOf[Name](got))
+	}
+	if err := ValueOf[LastError](got); err.error == nil {
+		t.Errorf("error hook not called on panic")
+	}
+
+	ng.Actions <- func(ctx Context) Context {
+		ctx = WithValue(ctx, Age(-1))
+		Abort(errInvalid)
+		return ctx
+	}
+	ng.Actions <- Set(Age(41))
+	got = ng.batch(Set(Name("Bond")))(ctx)
+
+	if ValueOf[Name](got) != "Bond" {
+		t.Errorf("action before the failure discarded: %s", ValueOf[Name](got))
+	}
+	if ValueOf[Age](got) != 41 {
+		t.Errorf("aborted action committed, or following action discarded: %d", ValueOf[Age](got))
+	}
+	if err := ValueOf[LastError](got); !errors.Is(err.error, errInvalid) {
+		t.Errorf("error hook: got %v, want %v", err.error, errInvalid)
+	}
+}
+
+func TestTransactOnce(t *testing.T) {
+	type Name string
+
+	var buf strings.Builder
+	ng := newEngine(WidgetFunc(func(ctx Context) *Node { return Get(`<p>`).SetText(string(ValueOf[Name](ctx))) }), LogDiffs)
+	ng.logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	ng.turncrank(ng.batch(Set(Name("Bond"))))
+	if n := strings.Count(buf.String(), "context changed"); n != 1 {
+		t.Errorf("changes of one action logged %d times: %s", n, buf.String())
+	}
+}
@@ -2,6 +2,7 @@ package rx
 
 import (
 	"syscall/js"
+	"time"
 )
 
 // JSUpdate is a callback to let the JS world trigger a new rendering cycle.
@@ -13,9 +14,13 @@ func (ngx *Engine) JSUpdate() js.Func {
 		evt := args[0].Int()
 		// terminate early
 		if IntentType(evt) == Seppuku {
-			close(ngx.XAS)
+			go ngx.Close()
 			return js.Null()
 		}
+		// handshake, the shim listens to registered intents
+		if IntentType(evt) == NoIntent {
+			return jsIntents()
+		}
 
 		cf := CallFrame{
 			Entity:     uint32(args[1].Int()),
@@ -47,7 +52,11 @@ func (ngx *Engine) JSUpdate() js.Func {
 		if cont := world.Get("continuation"); !cont.IsUndefined() {
 			cf.Continuation = make(chan CallFrame)
 			go ngx.ReactToIntent(cf)
-			cf := <-cf.Continuation
+			select {
+			case cf = <-cf.Continuation:
+			case <-ngx.life.Done():
+				return js.Null()
+			}
 			args := js.Global().Get("Array").New()
 			args.Call("push", cf.Returns[0])
 			args.Call("push", cf.Returns[1])
@@ -68,11 +77,30 @@ func (ngx *Engine) JSUpdate() js.Func {
 func (ngx *Engine) DrawAndLoop(drawfn js.Value) {
 	uintArr := js.Global().Get("Uint8Array")
 
-	ngx.Actions <- func(c Context) Context { return WithValue(c, ngx.Root) }
+	select {
+	case ngx.Actions <- ngx.mount:
+	case <-ngx.life.Done():
+		return
+	}
 	for vm := range ngx.XAS {
 		prog := uintArr.New(len(vm))
 		js.CopyBytesToJS(prog, vm)
-		ngx.ReleaseXAS(vm)
+		start := time.Now()
 		drawfn.Invoke(prog)
+		ngx.drawtime = time.Since(start)
+		ngx.ReleaseXAS(vm)
+	}
+}
+
+// jsIntents returns the intents registered with [RegisterIntent], as a list of {code, event, registers}
+func jsIntents() js.Value {
+	var table []any
+	for it, s := range registeredIntents {
+		regs := make([]any, len(s.registers))
+		for i, r := range s.registers {
+			regs[i] = r
+		}
+		table = append(table, map[string]any{"code": int(it), "event": s.event, "registers": regs})
 	}
+	return js.ValueOf(table)
 }
@@ -0,0 +1,197 @@
+package rx
+
+import (
+	"hash/maphash"
+	"iter"
+	"math/bits"
+	"slices"
+)
+
+// vctx is a persistent map of values, keyed by type or by [Key], implemented as a hash array mapped trie (see Bagwell, “Ideal Hash Trees”).
+// A trie is never modified once built: updates copy the path down to the modified leaf, and share the rest.
+// This makes contexts cheap to derive, and safe to retain and share between goroutines.
+//
+// The nil trie is valid, and empty.
+type vctx struct {
+	bmap  uint32 // slots present, indexed by 5 bits of the key hash
+	slots []vslot
+}
+
+// vslot holds either a sub-trie, or a leaf
+type vslot struct {
+	sub  *vctx
+	leaf *vleaf
+}
+
+type vleaf struct {
+	key  any
+	val  any
+	hash uint64
+	next *vleaf // keys with the same hash
+}
+
+var vseed = maphash.MakeSeed()
+
+const vbits = 5
+
+func vhash(key any) uint64 { return maphash.Comparable(vseed, key) }
+
+// get returns the value stored at key
+func (n *vctx) get(key any) (any, bool) {
+	if l := n.leaf(key); l != nil {
+		return l.val, true
+	}
+	return nil, false
+}
+
+// leaf returns the leaf holding key, or nil.
+// Leaves are allocated when a value is set, so they can be compared to know if a value was set again.
+func (n *vctx) leaf(key any) *vleaf {
+	h := vhash(key)
+	for shift := uint(0); n != nil; shift += vbits {
+		bit := uint32(1) << ((h >> shift) & 31)
+		if n.bmap&bit == 0 {
+			return nil
+		}
+		s := n.slots[bits.OnesCount32(n.bmap&(bit-1))]
+		if s.sub != nil {
+			n = s.sub
+			continue
+		}
+		for l := s.leaf; l != nil; l = l.next {
+			if l.key == key {
+				return l
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// with returns a trie where key is set to val
+func (n *vctx) with(key any, val any) *vctx {
+	return n.insert(&vleaf{key: key, val: val, hash: vhash(key)}, 0)
+}
+
+func (n *vctx) insert(l *vleaf, shift uint) *vctx {
+	c := new(vctx)
+	if n != nil {
+		c.bmap, c.slots = n.bmap, slices.Clone(n.slots)
+	}
+
+	bit := uint32(1) << ((l.hash >> shift) & 31)
+	idx := bits.OnesCount32(c.bmap & (bit - 1))
+	if c.bmap&bit == 0 {
+		c.bmap |= bit
+		c.slots = slices.Insert(c.slots, idx, vslot{leaf: l})
+		return c
+	}
+
+	switch s := c.slots[idx]; {
+	case s.sub != nil:
+		c.slots[idx] = vslot{sub: s.sub.insert(l, shift+vbits)}
+	case s.leaf.hash == l.hash:
+		// replace the key in the collision list, if present
+		for o := s.leaf; o != nil; o = o.next {
+			if o.key != l.key {
+				l = &vleaf{key: o.key, val: o.val, hash: o.hash, next: l}
+			}
+		}
+		c.slots[idx] = vslot{leaf: l}
+	default:
+		c.slots[idx] = vslot{sub: (*vctx)(nil).insert(s.leaf, shift+vbits).insert(l, shift+vbits)}
+	}
+	return c
+}
+
+// all iterates over the values in the trie, in no particular order
+func (n *vctx) all() iter.Seq2[any, any] {
+	return func(yield func(any, any) bool) { n.walk(yield) }
+}
+
+func (n *vctx) walk(yield func(any, any) bool) bool {
+	if n == nil {
+		return true
+	}
+	for _, s := range n.slots {
+		if s.sub != nil {
+			if !s.sub.walk(yield) {
+				return false
+			}
+			continue
+		}
+		for l := s.leaf; l != nil; l = l.next {
+			if !yield(l.key, l.val) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// changed iterates over the keys set in n or m, with a different leaf.
+// Sub-tries shared between n and m are skipped, so the cost is proportional to the number of changes.
+func (n *vctx) changed(m *vctx) iter.Seq[any] {
+	return func(yield func(any) bool) { vdiff(n, m, n, m, yield) }
+}
+
+// vdiff compares the sub-tries a and b at the same position, in roots ra and rb.
+func vdiff(a, b, ra, rb *vctx, yield func(any) bool) bool {
+	if a == b {
+		return true
+	}
+
+	var ba, bb uint32
+	if a != nil {
+		ba = a.bmap
+	}
+	if b != nil {
+		bb = b.bmap
+	}
+
+	for set := ba | bb; set != 0; set &= set - 1 {
+		bit := set & -set
+		var sa, sb vslot
+		if ba&bit != 0 {
+			sa = a.slots[bits.OnesCount32(ba&(bit-1))]
+		}
+		if bb&bit != 0 {
+			sb = b.slots[bits.OnesCount32(bb&(bit-1))]
+		}
+
+		switch {
+		case sa == sb:
+		case sa.sub != nil && sb.sub != nil:
+			if !vdiff(sa.sub, sb.sub, ra, rb, yield) {
+				return false
+			}
+		default:
+			// different shapes, compare the leaves one by one
+			for k := range sa.keys() {
+				if rb.leaf(k) != ra.leaf(k) && !yield(k) {
+					return false
+				}
+			}
+			for k := range sb.keys() {
+				if ra.leaf(k) == nil && !yield(k) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func (s vslot) keys() iter.Seq[any] {
+	return func(yield func(any) bool) {
+		if s.sub != nil {
+			s.sub.walk(func(k any, _ any) bool { return yield(k) })
+			return
+		}
+		for l := s.leaf; l != nil; l = l.next {
+			if !yield(l.key) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package rx
+
+import (
+	"slices"
+	"strconv"
+)
+
+// vnode is a retained copy of an element, as it was last sent to the browser.
+// Unlike [Node], it outlives the rendering pass (nodes are returned to the pool).
+type vnode struct {
+	tag      string
+	classes  string
+	text     string
+	ntt      Entity
+	target   string // for portals, see [Portal]
+	owner    *vnode // for portals, the element holding it
+	from     Entity // entity in the previous generation, for reused nodes
+	attrs    []Attr
+	styles   []Attr // style declarations, see [Node.SetStyle]
+	props    []Attr // JSON-encoded, see [encodeProps]
+	children []*vnode
+}
+
+// vtree is a bi-generational copy of the element tree.
+// It is recorded on v0 during [serialize], while v1 is what the browser currently displays.
+// The two generations are diffed to patch the DOM in place, instead of rebuilding it.
+type vtree struct {
+	v0, v1 []*vnode
+	p0, p1 []*vnode          // portals, outside of the element tree
+	ids    map[Entity]*vnode // lazy index of v1, only built for reuse
+	stack  []*vnode
+}
+
+// ngen starts recording a new generation of elements
+func (t *vtree) ngen() {
+	t.v1, t.v0 = t.v0, nil
+	t.p1, t.p0 = t.p0, nil
+	t.ids = nil
+	t.stack = t.stack[:0]
+}
+
+// discard drops the generation being recorded
+func (t *vtree) discard() {
+	t.v0, t.p0 = nil, nil
+	t.stack = t.stack[:0]
+}
+
+// open attaches v to the current element, and makes it the current element.
+func (t *vtree) open(v *vnode) {
+	if len(t.stack) == 0 {
+		t.v0 = append(t.v0, v)
+	} else {
+		p := t.stack[len(t.stack)-1]
+		p.children = append(p.children, v)
+	}
+	t.stack = append(t.stack, v)
+}
+
+// portal records a portal, and makes it the current element.
+// Its children are not part of the element tree of its parent, since they are rendered elsewhere.
+func (t *vtree) portal(target string) {
+	v := &vnode{tag: "portal", target: target}
+	if len(t.stack) > 0 {
+		v.owner = t.stack[len(t.stack)-1]
+	}
+	t.p0 = append(t.p0, v)
+	t.stack = append(t.stack, v)
+}
+
+func (t *vtree) close() { t.stack = t.stack[:len(t.stack)-1] }
+
+// graft carries from the previous generation the sub-tree rooted at from.
+// entities are renamed following ren, as done in [etree.reuse].
+// The portals held in the sub-tree are carried too, and returned:
+// unlike the elements, their content is not moved with the sub-tree.
+func (t *vtree) graft(from Entity, ren map[Entity]Entity) []*vnode {
+	if t.ids == nil {
+		t.ids = make(map[Entity]*vnode)
+		var index func(vs []*vnode)
+		index = func(vs []*vnode) {
+			for _, v := range vs {
+				if v.ntt != 0 {
+					t.ids[v.ntt] = v
+				}
+				index(v.children)
+			}
+		}
+		index(t.v1)
+		index(t.p1)
+	}
+
+	old := t.ids[from]
+	if old == nil {
+		return nil
+	}
+
+	cloned := make(map[*vnode]*vnode)
+	var clone func(v *vnode) *vnode
+	clone = func(v *vnode) *vnode {
+		c := *v
+		c.from = 0
+		if to, ok := ren[v.ntt]; ok {
+			c.ntt = to
+		}
+		c.children = make([]*vnode, len(v.children))
+		for i := range v.children {
+			c.children[i] = clone(v.children[i])
+		}
+		cloned[v] = &c
+		return &c
+	}
+
+	v := clone(old)
+	v.from = from
+	t.open(v)
+	t.close()
+
+	// portals are recorded in order, a portal nested in another one comes after it
+	var portals []*vnode
+	for _, p := range t.p1 {
+		if owner, ok := cloned[p.owner]; ok {
+			c := clone(p)
+			c.owner = owner
+			t.p0 = append(t.p0, c)
+			portals = append(portals, c)
+		}
+	}
+	return portals
+}
+
+// diff returns a program patching the DOM from the previous generation to the current one.
+// If the elements cannot be patched in place (e.g. an element is reused under a different parent),
+// a nil program is returned, and the DOM must be rebuilt instead.
+func (t *vtree) diff(vm XAS) XAS {
+	vm = vm.AddInstr(OpPatch)
+	vm, ok := diffChildren(t.v1, t.v0, vm)
+	if !ok {
+		return nil
+	}
+
+	// each target is patched on its own, so opening a modal leaves the rest of the view in place.
+	// The browser creates a target appearing, and removes one left empty.
+	var targets []string
+	for _, p := range slices.Concat(t.p0, t.p1) {
+		if !slices.Contains(targets, p.target) {
+			targets = append(targets, p.target)
+		}
+	}
+	for _, target := range targets {
+		mark := len(vm)
+		vm = vm.AddInstr(OpPortal, target)
+		start := len(vm)
+		if vm, ok = diffChildren(portalChildren(t.p1, target), portalChildren(t.p0, target), vm); !ok {
+			return nil
+		}
+		if len(vm) == start {
+			vm = vm[:mark]
+		} else {
+			vm = vm.AddInstr(OpNext)
+		}
+	}
+	return vm.AddInstr(OpTerm)
+}
+
+// portalChildren returns the elements rendered at target, in order.
+func portalChildren(portals []*vnode, target string) []*vnode {
+	var children []*vnode
+	for _, p := range portals {
+		if p.target == target {
+			children = append(children, p.children...)
+		}
+	}
+	return children
+}
+
+// diffChildren patches the children old of the current element into cur.
+// The browser cursor is positioned before the first child element, and left after the last one.
+func diffChildren(old, cur []*vnode, vm XAS) (XAS, bool) {
+	live := slices.Clone(old) // mirrors the DOM children as the patch is applied
+
+	// elements reused in this generation must not be patched into other elements
+	var kept map[Entity]bool
+	for _, c := range cur {
+		if c.from != 0 {
+			if kept == nil {
+				kept = make(map[Entity]bool)
+			}
+			kept[c.from] = true
+		}
+	}
+
+	skip := 0
+	flush := func() {
+		if skip > 0 {
+			vm = vm.AddInstr(OpSkip, strconv.Itoa(skip))
+			skip = 0
+		}
+	}
+
+	for i, c := range cur {
+		switch {
+		case c.from != 0:
+			j := slices.IndexFunc(live[i:], func(v *vnode) bool { return v.ntt == c.from })
+			if j == -1 {
+				return vm, false
+			}
+			if j > 0 {
+				flush()
+				vm = vm.AddInstr(OpMove, strconv.FormatUint(uint64(c.from), 10))
+				v := live[i+j]
+				live = slices.Insert(slices.Delete(live, i+j, i+j+1), i, v)
+			}
+		case i < len(live) && !kept[live[i].ntt] && live[i].tag == c.tag:
+			// patch in place
+		default:
+			flush()
+			var ok bool
+			if vm, ok = c.create(vm); !ok {
+				return vm, false
+			}
+			live = slices.Insert(live, i, c)
+			continue
+		}
+
+		mark, pending := len(vm), skip
+		flush()
+		vm = vm.AddInstr(OpEnter)
+		start := len(vm)
+
+		var ok bool
+		if vm, ok = diffNode(live[i], c, vm); !ok {
+			return vm, false
+		}
+		if len(vm) == start {
+			vm, skip = vm[:mark], pending+1
+		} else {
+			vm = vm.AddInstr(OpNext)
+		}
+	}
+
+	if len(live) > len(cur) {
+		flush()
+		for range live[len(cur):] {
+			vm = vm.AddInstr(OpRemove)
+		}
+	}
+
+	return vm, true
+}
+
+// diffNode patches the current element from old to cur, which share the same tag name.
+func diffNode(old, cur *vnode, vm XAS) (XAS, bool) {
+	if old.classes != cur.classes {
+		if cur.classes == "" {
+			vm = vm.AddInstr(OpRemoveAttr, "class")
+		} else {
+			vm = vm.AddInstr(OpSetClass, cur.classes)
+		}
+	}
+
+	if old.ntt != cur.ntt {
+		if cur.ntt == 0 {
+			vm = vm.AddInstr(OpRemoveAttr, "id")
+		} else {
+			vm = vm.AddInstr(OpSetID, strconv.FormatUint(uint64(cur.ntt), 10))
+		}
+	}
+
+	for _, a := range cur.attrs {
+		if !slices.Contains(old.attrs, a) {
+			vm = vm.AddInstr(OpSetAttr, a.Name, a.Value)
+		}
+	}
+	for _, a := range old.attrs {
+		if !slices.ContainsFunc(cur.attrs, func(b Attr) bool { return a.Name == b.Name }) {
+			vm = vm.AddInstr(OpRemoveAttr, a.Name)
+		}
+	}
+
+	// declarations are patched one by one, an empty value removes them
+	for _, d := range cur.styles {
+		if !slices.Contains(old.styles, d) {
+			vm = vm.AddInstr(OpSetStyle, d.Name, d.Value)
+		}
+	}
+	for _, d := range old.styles {
+		if !slices.ContainsFunc(cur.styles, func(e Attr) bool { return d.Name == e.Name }) {
+			vm = vm.AddInstr(OpSetStyle, d.Name, "")
+		}
+	}
+
+	if old.text != cur.text {
+		vm = vm.AddInstr(OpSetText, cur.text)
+	}
+
+	vm, ok := diffChildren(old.children, cur.children, vm)
+	if !ok {
+		return vm, false
+	}
+	// the user can change properties (e.g. typing in an input), so they are always re-asserted
+	for _, p := range cur.props {
+		vm = vm.AddInstr(OpSetProp, p.Name, p.Value)
+	}
+	return vm, true
+}
+
+// create emits the instructions creating the element from scratch.
+// Reused elements cannot be created, since this would lose their identity.
+func (v *vnode) create(vm XAS) (XAS, bool) {
+	if v.from != 0 {
+		return vm, false
+	}
+
+	vm = vm.AddInstr(OpCreateElement, v.tag)
+	if v.classes != "" {
+		vm = vm.AddInstr(OpSetClass, v.classes)
+	}
+	if v.ntt != 0 {
+		vm = vm.AddInstr(OpSetID, strconv.FormatUint(uint64(v.ntt), 10))
+	}
+	for _, a := range v.attrs {
+		vm = vm.AddInstr(OpSetAttr, a.Name, a.Value)
+	}
+	for _, d := range v.styles {
+		vm = vm.AddInstr(OpSetStyle, d.Name, d.Value)
+	}
+	if v.text != "" {
+		vm = vm.AddInstr(OpAddText, v.text)
+	}
+	for _, c := range v.children {
+		var ok bool
+		if vm, ok = c.create(vm); !ok {
+			return vm, false
+		}
+	}
+	for _, p := range v.props {
+		vm = vm.AddInstr(OpSetProp, p.Name, p.Value)
+	}
+	return vm.AddInstr(OpNext), true
+}
@@ -0,0 +1,234 @@
+package rx
+
+import (
+	"encoding/binary"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiffTree(t *testing.T) {
+	cases := []struct {
+		name     string
+		old, cur func() *Node
+		want     []string
+	}{
+		{"same tree",
+			func() *Node { return Get(`<div class="flex"><p>hello</p></div>`) },
+			func() *Node { return Get(`<div class="flex"><p>hello</p></div>`) },
+			nil},
+		{"update attribute",
+			func() *Node { return Get(`<div><p>hello</p><p title="a">world</p></div>`) },
+			func() *Node { return Get(`<div><p>hello</p><p title="b">world</p></div>`) },
+			[]string{"Enter", "Skip 1", "Enter", "SetAttr title b", "Next", "Next"}},
+		{"remove attribute and class",
+			func() *Node { return Get(`<div class="flex" title="a">`) },
+			func() *Node { return Get(`<div>`) },
+			[]string{"Enter", "RemoveAttr class", "RemoveAttr title", "Next"}},
+		{"replace text",
+			func() *Node { return Get(`<div><p>hello</p></div>`) },
+			func() *Node { return Get(`<div><p>world</p></div>`) },
+			[]string{"Enter", "Enter", "SetText world", "Next", "Next"}},
+		{"insert and remove",
+			func() *Node { return Get(`<ul><li>1</li><li>2</li></ul>`) },
+			func() *Node { return Get(`<ul><li>1</li><span>3</span></ul>`) },
+			[]string{"Enter", "Skip 1", "CreateElement span", "AddText 3", "Next", "Remove", "Next"}},
+		{"append",
+			func() *Node { return Get(`<ul><li>1</li></ul>`) },
+			func() *Node { return Get(`<ul><li>1</li><li>2</li></ul>`) },
+			[]string{"Enter", "Skip 1", "CreateElement li", "AddText 2", "Next", "Next"}},
+		{"update style declarations",
+			func() *Node { return Get(`<div style="color: red; top: 0">`).SetStyle("width", "10px") },
+			func() *Node {
+				return Get(`<div style="color: red">`).SetStyle("width", "20px").SetStyle("--gap", "4px")
+			},
+			[]string{"Enter", "SetStyle width 20px", "SetStyle --gap 4px", "SetStyle top ", "Next"}},
+		{"re-assert properties",
+			func() *Node { return Get(`<div><p>hello</p></div>`).AddChildren(Get(`<input>`).SetProp("value", "a")) },
+			func() *Node { return Get(`<div><p>hello</p></div>`).AddChildren(Get(`<input>`).SetProp("value", "a")) },
+			[]string{"Enter", "Skip 1", "Enter", "SetProp value \"a\"", "Next", "Next"}},
+		{"create with properties",
+			func() *Node { return Get(`<div>`) },
+			func() *Node {
+				return Get(`<div>`).AddChildren(Get(`<select>`).SetProp("value", "b").AddChildren(Get(`<option value="b">`)))
+			},
+			[]string{"Enter", "CreateElement select", "CreateElement option", "SetAttr value b", "Next", "SetProp value \"b\"", "Next", "Next"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var (
+				et  etree
+				vt  vtree
+				cnt Counter
+			)
+			serialize(c.old(), &et, &vt, &cnt, nil)
+			et.ngen()
+			vt.ngen()
+			serialize(c.cur(), &et, &vt, &cnt, nil)
+
+			got := disasm(vt.diff(nil))
+			want := append(append([]string{"Patch"}, c.want...), "Term")
+			if !cmp.Equal(got, want) {
+				t.Errorf("diff: %s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestDiffReuse(t *testing.T) {
+	type first struct{}
+	type second struct{}
+
+	ng := New(nil)
+	var items []*Node
+	ng.Root = WidgetFunc(func(ctx Context) *Node {
+		items = items[:0]
+		for _, k := range []func(Context) *Node{reuseOrKeep[first], reuseOrKeep[second]} {
+			items = append(items, k(ctx))
+		}
+		if ng.gen == 1 {
+			items[0], items[1] = items[1], items[0]
+		}
+		return Get(`<ul>`).AddChildren(items...)
+	})
+
+	if xas := ng.turncrank(DoNothing); len(xas) == 0 || xas[0] == OpPatch {
+		t.Fatalf("first turn must rebuild the tree")
+	}
+	xas := ng.turncrank(DoNothing)
+	want := []string{"Patch", "Enter", "Move 4", "Enter", "SetID 5", "Next", "Enter", "SetID 3", "Next", "Next", "Term"}
+	if got := disasm(xas); !cmp.Equal(got, want) {
+		t.Errorf("swap reused nodes: %s", cmp.Diff(want, got))
+	}
+}
+
+func TestReuseNotRendered(t *testing.T) {
+	type kept struct{}
+
+	ng := New(nil)
+	ng.Root = WidgetFunc(func(ctx Context) *Node {
+		li := reuseOrKeep[kept](ctx)
+		if ng.gen == 1 {
+			return Get(`<ul>`) // kept, but not rendered: the element is from two generations ago
+		}
+		return Get(`<ul>`).AddChildren(li)
+	})
+
+	ng.turncrank(DoNothing)
+	ng.turncrank(DoNothing)
+	for _, in := range disasm(ng.turncrank(DoNothing)) {
+		if strings.HasPrefix(in, "Reuse") {
+			t.Errorf("element not in the previous generation reused: %s", in)
+		}
+	}
+}
+
+func reuseOrKeep[T any](ctx Context) *Node {
+	if n := Reuse[T](ctx); n != nil {
+		return n
+	}
+	n := Get(`<li>`)
+	Keep[T](ctx, n)
+	return n
+}
+
+// disasm returns a textual representation of the program, one instruction per line
+func disasm(vm XAS) []string {
+	var out []string
+	for len(vm) > 0 {
+		op := vm[0]
+		vm = vm[1:]
+		args := []string{OpNames[op]}
+		for range OpArity[op] {
+			sz := binary.BigEndian.Uint16(vm)
+			args = append(args, string(vm[2:2+sz]))
+			vm = vm[2+sz:]
+		}
+		out = append(out, strings.Join(args, " "))
+	}
+	return out
+}
+
+func TestDiffPortal(t *testing.T) {
+	cases := []struct {
+		name     string
+		old, cur func() *Node
+		want     []string // nil for a full rebuild
+	}{
+		{"patch in place",
+			func() *Node { return Get(`<div>`).AddChildren(Portal("overlay", Get(`<p>a</p>`))) },
+			func() *Node { return Get(`<div>`).AddChildren(Portal("overlay", Get(`<p>b</p>`))) },
+			[]string{"Patch", "Portal overlay", "Enter", "SetText b", "Next", "Next", "Term"}},
+		{"unchanged",
+			func() *Node { return Get(`<div>`).AddChildren(Portal("overlay", Get(`<p>a</p>`))) },
+			func() *Node { return Get(`<div class="x">`).AddChildren(Portal("overlay", Get(`<p>a</p>`))) },
+			[]string{"Patch", "Enter", "SetClass x", "Next", "Term"}},
+		{"open",
+			func() *Node { return Get(`<div><input></div>`) },
+			func() *Node { return Get(`<div><input></div>`).AddChildren(Portal("overlay", Get(`<p>a</p>`))) },
+			[]string{"Patch", "Portal overlay", "CreateElement p", "AddText a", "Next", "Next", "Term"}},
+		{"close",
+			func() *Node { return Get(`<div><input></div>`).AddChildren(Portal("#toasts", Get(`<p>a</p>`))) },
+			func() *Node { return Get(`<div><input></div>`) },
+			[]string{"Patch", "Portal #toasts", "Remove", "Next", "Term"}},
+		{"move to another target",
+			func() *Node { return Nothing(Portal("overlay", Get(`<p>a</p>`)), Get(`<div>`)) },
+			func() *Node { return Nothing(Portal("#toasts", Get(`<p>a</p>`)), Get(`<div>`)) },
+			[]string{"Patch", "Portal #toasts", "CreateElement p", "AddText a", "Next", "Next", "Portal overlay", "Remove", "Next", "Term"}},
+		{"shared target",
+			func() *Node { return Nothing(Portal("overlay", Get(`<p>a</p>`)), Portal("overlay", Get(`<p>c</p>`))) },
+			func() *Node { return Nothing(Portal("overlay", Get(`<p>b</p>`)), Portal("overlay", Get(`<p>c</p>`))) },
+			[]string{"Patch", "Portal overlay", "Enter", "SetText b", "Next", "Next", "Term"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var (
+				et  etree
+				vt  vtree
+				cnt Counter
+			)
+			serialize(c.old(), &et, &vt, &cnt, nil)
+			et.ngen()
+			vt.ngen()
+			serialize(c.cur(), &et, &vt, &cnt, nil)
+
+			if got := disasm(vt.diff(nil)); !cmp.Equal(got, c.want) {
+				t.Errorf("diff: %s", cmp.Diff(c.want, got))
+			}
+		})
+	}
+}
+
+func TestGraftPortal(t *testing.T) {
+	type dialog struct{}
+
+	ng := New(nil)
+	ng.Root = WidgetFunc(func(ctx Context) *Node {
+		if n := Reuse[dialog](ctx); n != nil {
+			return Get(`<main>`).AddChildren(n)
+		}
+		n := Get(`<section>`).AddChildren(Portal("overlay", Get(`<p>open</p>`).OnIntent(Click, DoNothing)))
+		Keep[dialog](ctx, n)
+		return Get(`<main>`).AddChildren(n)
+	})
+
+	ng.turncrank(DoNothing)
+	for turn := 1; turn < 3; turn++ {
+		prog := disasm(ng.turncrank(DoNothing))
+		t.Log(prog)
+		full := ng.buf
+		if ng.turn.Patch {
+			full = ng.pbuf
+		}
+		if !slices.Contains(disasm(full), "Portal overlay") {
+			t.Errorf("turn %d: portal of the reused element not rendered again: %v", turn, disasm(full))
+		}
+		if slices.Contains(prog, "Remove") {
+			t.Errorf("turn %d: portal of the reused element removed: %v", turn, prog)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package rx
+
+type watcher struct {
+	typ any
+	fn  func(old, cur any) Action
+}
+
+// watchers may change values watched by others, but must settle eventually
+const maxWatchRounds = 8
+
+// Watch registers fn to run when an action changes the value of type T, or at key if given.
+// The action returned by fn (if not nil) is applied in the same turn, before rendering:
+//
+//	ng := rx.New(root, rx.Watch(func(old, cur Selection) rx.Action {
+//		return fetchDetails(cur)
+//	}))
+//
+// Watchers compare the values at the end of the actions with the committed ones:
+// setting a value again counts as a change, even if the values are equal.
+func Watch[T any](fn func(old, cur T) Action, key ...*Key[T]) Action {
+	return func(ctx Context) Context {
+		ctx.ng.watchers = append(ctx.ng.watchers, watcher{
+			typ: keyOf(key),
+			fn: func(old, cur any) Action {
+				o, _ := old.(T) // zero value if missing
+				c, _ := cur.(T)
+				return fn(o, c)
+			},
+		})
+		return ctx
+	}
+}
+
+// watch runs the watchers of the values changed from the committed state.
+// Changes made by watchers are watched too, in the following round.
+// Watchers are applied as actions: a failing one is rolled back, and reported to the [OnError] hooks.
+func (ng *Engine) watch(ctx Context) Context {
+	old := ng.ctx
+	for range maxWatchRounds {
+		cur := ctx.vx
+		if cur == old {
+			return ctx
+		}
+
+		for _, w := range ng.watchers {
+			lo, lc := old.leaf(w.typ), cur.leaf(w.typ)
+			if lo == lc {
+				continue
+			}
+
+			var ov, cv any
+			if lo != nil {
+				ov = lo.val
+			}
+			if lc != nil {
+				cv = lc.val
+			}
+			act := func(ctx Context) Context {
+				if act := w.fn(ov, cv); act != nil {
+					return act(ctx)
+				}
+				return noAction
+			}
+			if c := ng.transact(act, ctx); c != noAction {
+				ctx = c
+			}
+		}
+		old = cur
+	}
+
+	ng.logger.Warn("watchers keep changing the context, giving up", "rounds", maxWatchRounds)
+	return ctx
+}
@@ -0,0 +1,78 @@
+package rx
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWatch(t *testing.T) {
+	type Selection int
+	type Details string
+	type Retries int
+
+	var changes [][2]Selection
+	ng := New(WidgetFunc(func(ctx Context) *Node { return Get(`<div>`) }),
+		Watch(func(old, cur Selection) Action {
+			changes = append(changes, [2]Selection{old, cur})
+			return LoadContext(Details("details of " + string(rune('0'+cur))))
+		}),
+		// watchers changing their own value stop eventually
+		Watch(func(old, cur Retries) Action { return LoadContext(cur + 1) }),
+	)
+	defer ng.Close()
+	ng.mx.Lock()
+	defer ng.mx.Unlock()
+
+	ng.turncrank(DoNothing)
+	ng.turncrank(LoadContext(Selection(1)))
+	ng.turncrank(LoadContext(Selection(2)))
+	ng.turncrank(DoNothing)
+
+	if len(changes) != 2 || changes[0] != [2]Selection{0, 1} || changes[1] != [2]Selection{1, 2} {
+		t.Errorf("watched changes: %v", changes)
+	}
+	if got := ValueOf[Details](Context{vx: ng.ctx}); got != "details of 2" {
+		t.Errorf("follow-up action not committed, got %q", got)
+	}
+
+	ng.turncrank(LoadContext(Retries(0)))
+	if got := ValueOf[Retries](Context{vx: ng.ctx}); got != maxWatchRounds {
+		t.Errorf("cyclic watcher: got %d rounds, want %d", got, maxWatchRounds)
+	}
+}
+
+func TestWatchFailure(t *testing.T) {
+	type Selection int
+	type Details string
+	type LastError struct{ error }
+	errStale := errors.New("stale selection")
+
+	ng := New(WidgetFunc(func(ctx Context) *Node { return Get(`<div>`) }),
+		OnError(func(err error) Action { return Set(LastError{err}) }),
+		Watch(func(old, cur Selection) Action { panic("watcher failed") }),
+		Watch(func(old, cur Selection) Action {
+			return func(ctx Context) Context {
+				ctx = WithValue(ctx, Details("partial"))
+				Abort(errStale)
+				return ctx
+			}
+		}),
+	)
+	defer ng.Close()
+	ng.mx.Lock()
+	defer ng.mx.Unlock()
+
+	ng.turncrank(DoNothing)
+	ng.turncrank(ng.batch(LoadContext(Selection(1))))
+
+	ctx := Context{vx: ng.ctx}
+	if ValueOf[Selection](ctx) != 1 {
+		t.Errorf("action discarded by failing watchers")
+	}
+	if got := ValueOf[Details](ctx); got != "" {
+		t.Errorf("aborted watcher committed %q", got)
+	}
+	if err := ValueOf[LastError](ctx); !errors.Is(err.error, errStale) {
+		t.Errorf("error hook: got %v, want %v", err.error, errStale)
+	}
+}
@@ -0,0 +1,288 @@
+package xas
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/TroutSoftware/rx"
+)
+
+// NodeType distinguishes elements from text in the document
+type NodeType int
+
+const (
+	ElementNode NodeType = iota
+	TextNode
+	FragmentNode
+)
+
+// Node is a node of the in-memory document.
+// It models the subset of the DOM used by the rx engine.
+type Node struct {
+	Type    NodeType
+	TagName string // for elements
+	Data    string // for text
+	Attrs   []rx.Attr
+	Props   []rx.Attr     // JSON-encoded values of the DOM properties
+	Styles  []Declaration // of the inline style, in the order they were set
+
+	Parent   *Node
+	Children []*Node
+}
+
+// ID returns the value of the id attribute
+func (n *Node) ID() string { v, _ := n.Attr("id"); return v }
+
+// Attr returns the value of the attribute, and if the attribute is set.
+func (n *Node) Attr(name string) (string, bool) {
+	for _, a := range n.Attrs {
+		if a.Name == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+func (n *Node) setAttr(name, value string) {
+	i := slices.IndexFunc(n.Attrs, func(a rx.Attr) bool { return a.Name == name })
+	if i == -1 {
+		n.Attrs = append(n.Attrs, rx.Attr{Name: name, Value: value})
+	} else {
+		n.Attrs[i].Value = value
+	}
+}
+
+// Prop returns the value of the DOM property, decoded from JSON, and if the property is set.
+func (n *Node) Prop(name string) (any, bool) {
+	for _, p := range n.Props {
+		if p.Name == name {
+			var v any
+			json.Unmarshal([]byte(p.Value), &v)
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func (n *Node) setProp(name, value string) {
+	i := slices.IndexFunc(n.Props, func(a rx.Attr) bool { return a.Name == name })
+	if i == -1 {
+		n.Props = append(n.Props, rx.Attr{Name: name, Value: value})
+	} else {
+		n.Props[i].Value = value
+	}
+}
+
+// Declaration is a declaration of the inline style.
+// As in the DOM, the !important flag is not part of the value, but its priority.
+type Declaration struct {
+	Name, Value string
+	Priority    string // "important", or empty
+}
+
+// Style returns the value of the inline style declaration, or an empty string.
+func (n *Node) Style(name string) string {
+	for _, d := range n.Styles {
+		if d.Name == name {
+			return d.Value
+		}
+	}
+	return ""
+}
+
+// StylePriority returns the priority of the inline style declaration, "important" or an empty string.
+func (n *Node) StylePriority(name string) string {
+	for _, d := range n.Styles {
+		if d.Name == name {
+			return d.Priority
+		}
+	}
+	return ""
+}
+
+func (n *Node) setStyle(name, value string) {
+	d := Declaration{Name: name}
+	d.Value, d.Priority = splitPriority(value)
+	i := slices.IndexFunc(n.Styles, func(d Declaration) bool { return d.Name == name })
+	switch {
+	case value == "" && i != -1:
+		n.Styles = slices.Delete(n.Styles, i, i+1)
+	case value == "":
+	case i == -1:
+		n.Styles = append(n.Styles, d)
+	default:
+		n.Styles[i] = d
+	}
+}
+
+// splitPriority separates the !important flag from a value, as style.setProperty expects it.
+func splitPriority(value string) (string, string) {
+	v := strings.TrimSpace(value)
+	const important = "important"
+	if len(v) < len(important) || !strings.EqualFold(v[len(v)-len(important):], important) {
+		return value, ""
+	}
+	v = strings.TrimSpace(v[:len(v)-len(important)])
+	if !strings.HasSuffix(v, "!") {
+		return value, ""
+	}
+	return strings.TrimSpace(v[:len(v)-1]), important
+}
+
+func (n *Node) removeAttr(name string) {
+	n.Attrs = slices.DeleteFunc(n.Attrs, func(a rx.Attr) bool { return a.Name == name })
+}
+
+// TextContent returns the concatenated text of the node and its descendants
+func (n *Node) TextContent() string {
+	if n.Type == TextNode {
+		return n.Data
+	}
+	var buf strings.Builder
+	for _, c := range n.Children {
+		buf.WriteString(c.TextContent())
+	}
+	return buf.String()
+}
+
+// ElementByID returns the element with the given id in the sub-tree rooted at n, or nil.
+func (n *Node) ElementByID(id string) *Node {
+	for _, c := range n.Children {
+		if c.Type != ElementNode {
+			continue
+		}
+		if c.ID() == id {
+			return c
+		}
+		if m := c.ElementByID(id); m != nil {
+			return m
+		}
+	}
+	return nil
+}
+
+// layer returns the layer of the portals rendered at target, among the children of n.
+func (n *Node) layer(target string) *Node {
+	for _, c := range n.Children {
+		if v, ok := c.Attr("data-portal"); ok && v == target {
+			return c
+		}
+	}
+	return nil
+}
+
+// HTML returns a textual representation of the node, and its descendants.
+// Attributes are written in the order they were set; text is not escaped.
+func (n *Node) HTML() string {
+	var buf strings.Builder
+	n.writeHTML(&buf)
+	return buf.String()
+}
+
+func (n *Node) writeHTML(buf *strings.Builder) {
+	switch n.Type {
+	case TextNode:
+		buf.WriteString(n.Data)
+		return
+	case FragmentNode:
+		for _, c := range n.Children {
+			c.writeHTML(buf)
+		}
+		return
+	}
+
+	buf.WriteString("<" + n.TagName)
+	for _, a := range n.Attrs {
+		buf.WriteString(" " + a.Name + `="` + a.Value + `"`)
+	}
+	if len(n.Styles) > 0 {
+		buf.WriteString(` style="`)
+		for i, d := range n.Styles {
+			if i > 0 {
+				buf.WriteString(" ")
+			}
+			buf.WriteString(d.Name + ": " + d.Value)
+			if d.Priority != "" {
+				buf.WriteString(" !" + d.Priority)
+			}
+			buf.WriteString(";")
+		}
+		buf.WriteString(`"`)
+	}
+	buf.WriteString(">")
+	for _, c := range n.Children {
+		c.writeHTML(buf)
+	}
+	buf.WriteString("</" + n.TagName + ">")
+}
+
+func (n *Node) firstChild() *Node {
+	if len(n.Children) == 0 {
+		return nil
+	}
+	return n.Children[0]
+}
+
+func (n *Node) firstElementChild() *Node {
+	for _, c := range n.Children {
+		if c.Type == ElementNode {
+			return c
+		}
+	}
+	return nil
+}
+
+func (n *Node) nextSibling() *Node {
+	if n.Parent == nil {
+		return nil
+	}
+	i := slices.Index(n.Parent.Children, n)
+	if i+1 == len(n.Parent.Children) {
+		return nil
+	}
+	return n.Parent.Children[i+1]
+}
+
+func (n *Node) nextElementSibling() *Node {
+	for s := n.nextSibling(); s != nil; s = s.nextSibling() {
+		if s.Type == ElementNode {
+			return s
+		}
+	}
+	return nil
+}
+
+// insertBefore moves c as a child of n, before ref (or last if ref is nil)
+func (n *Node) insertBefore(c, ref *Node) {
+	if c == ref {
+		return
+	}
+	c.remove()
+	c.Parent = n
+	if ref == nil {
+		n.Children = append(n.Children, c)
+		return
+	}
+	i := slices.Index(n.Children, ref)
+	n.Children = slices.Insert(n.Children, i, c)
+}
+
+func (n *Node) replaceWith(c *Node) {
+	p := n.Parent
+	if p == nil || c == n {
+		return
+	}
+	c.remove()
+	i := slices.Index(p.Children, n)
+	p.Children[i] = c
+	c.Parent, n.Parent = p, nil
+}
+
+func (n *Node) remove() {
+	if n.Parent == nil {
+		return
+	}
+	n.Parent.Children = slices.DeleteFunc(n.Parent.Children, func(c *Node) bool { return c == n })
+	n.Parent = nil
+}
@@ -0,0 +1,314 @@
+// Package xas interprets the programs (XAS) produced by the rx engine on an in-memory document.
+//
+// The interpreter follows the semantics of the redraw function in bootstrap.ts,
+// including the reuse and re-identification of elements across generations,
+// so tests and tools can assert on the document the browser would actually build.
+//
+//	doc := xas.NewDocument()
+//	xas, _, _ := ng.Dispatch(rx.CallFrame{})
+//	if err := doc.Apply(xas); err != nil {
+//		// handle invalid program
+//	}
+//	doc.Root.ElementByID("2").TextContent()
+package xas
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/TroutSoftware/rx"
+)
+
+// Document is the in-memory equivalent of the shadow root drawn by the engine.
+// It persists across turns, so successive programs apply to the result of the previous ones.
+type Document struct {
+	Root  *Node
+	Hosts map[string]*Node // mount points of the host page, by id (see [rx.Portal])
+	Gen   int              // incremented on each program, as in the browser
+}
+
+func NewDocument() *Document { return &Document{Root: &Node{Type: FragmentNode}} }
+
+// Instr is a decoded instruction of a program
+type Instr struct {
+	Op   rx.OpType
+	Args []string
+}
+
+func (in Instr) String() string {
+	var buf strings.Builder
+	buf.WriteString(rx.OpNames[in.Op])
+	for _, a := range in.Args {
+		buf.WriteString(" " + strconv.Quote(a))
+	}
+	return buf.String()
+}
+
+// Decode splits the program in instructions.
+func Decode(prog rx.XAS) ([]Instr, error) {
+	var out []Instr
+	for ip := 0; ip < len(prog); {
+		op := prog[ip]
+		if int(op) >= len(rx.OpNames) {
+			return out, fmt.Errorf("at %d: unknown instruction %d", ip, op)
+		}
+		ip++
+
+		in := Instr{Op: op}
+		for range rx.OpArity[op] {
+			if ip+2 > len(prog) {
+				return out, fmt.Errorf("at %d: truncated %s", ip, rx.OpNames[op])
+			}
+			sz := int(binary.BigEndian.Uint16(prog[ip:]))
+			ip += 2
+			if ip+sz > len(prog) {
+				return out, fmt.Errorf("at %d: truncated %s", ip, rx.OpNames[op])
+			}
+			in.Args = append(in.Args, string(prog[ip:ip+sz]))
+			ip += sz
+		}
+		out = append(out, in)
+	}
+	return out, nil
+}
+
+var ErrNoTerm = errors.New("invalid XAS code, no term instructions")
+
+// Apply executes the program on the document.
+// Nodes keep their identity across programs, when they are reused or patched in place.
+// If the program fails, the document can be left partially modified.
+func (d *Document) Apply(prog rx.XAS) error {
+	code, err := Decode(prog)
+	if err != nil {
+		return err
+	}
+
+	cur := d.Root
+	ndoc := &Node{Type: FragmentNode}
+	root, anchor := ndoc, ndoc
+	var next *Node
+
+	// portals built by the program, and the cursor to restore when leaving them
+	var (
+		portals = make(map[string]*Node)
+		order   []string
+		outside [][3]*Node
+	)
+	byID := func(id string) *Node {
+		if n := root.ElementByID(id); n != nil {
+			return n
+		}
+		for _, p := range portals {
+			if n := p.ElementByID(id); n != nil {
+				return n
+			}
+		}
+		if n := cur.ElementByID(id); n != nil {
+			return n
+		}
+		for _, h := range d.Hosts {
+			if n := h.ElementByID(id); n != nil {
+				return n
+			}
+		}
+		return nil
+	}
+
+	for i, in := range code {
+		fail := func(msg string, args ...any) error {
+			return fmt.Errorf("instruction %d (%s): %s", i, in, fmt.Sprintf(msg, args...))
+		}
+		elem := func(n *Node) (*Node, error) {
+			if n == nil || n.Type != ElementNode {
+				return nil, fail("no element at cursor")
+			}
+			return n, nil
+		}
+
+		switch in.Op {
+		case rx.OpTerm:
+			if root == ndoc {
+				// layers are on top of the view, host mount points are replaced
+				hosts := make(map[string]*Node)
+				for _, name := range order {
+					if id, ok := strings.CutPrefix(name, "#"); ok {
+						hosts[id] = portals[name]
+					} else {
+						ndoc.insertBefore(portals[name], nil)
+					}
+				}
+				d.Hosts = hosts
+
+				for _, c := range cur.Children {
+					c.Parent = nil
+				}
+				cur.Children = nil
+				for _, c := range ndoc.Children {
+					c.Parent = cur
+					cur.Children = append(cur.Children, c)
+				}
+			}
+			d.Gen++
+			return nil
+
+		case rx.OpPortal:
+			name := in.Args[0]
+			p := portals[name]
+			switch {
+			case root != ndoc:
+				// a target appearing is created, see the removal of empty targets in OpNext
+				if id, ok := strings.CutPrefix(name, "#"); ok {
+					if p = d.Hosts[id]; p == nil {
+						p = &Node{Type: FragmentNode}
+						if d.Hosts == nil {
+							d.Hosts = make(map[string]*Node)
+						}
+						d.Hosts[id] = p
+					}
+				} else if p = cur.layer(name); p == nil {
+					p = &Node{Type: ElementNode, TagName: "div", Attrs: []rx.Attr{{Name: "data-portal", Value: name}}}
+					cur.insertBefore(p, nil)
+				}
+			case p == nil && strings.HasPrefix(name, "#"):
+				p = &Node{Type: FragmentNode}
+			case p == nil:
+				p = &Node{Type: ElementNode, TagName: "div", Attrs: []rx.Attr{{Name: "data-portal", Value: name}}}
+			}
+			if root == ndoc && portals[name] == nil {
+				portals[name], order = p, append(order, name)
+			}
+			outside = append(outside, [3]*Node{anchor, next, p})
+			anchor, next = p, nil
+			if root != ndoc {
+				next = p.firstElementChild()
+			}
+
+		case rx.OpPatch:
+			root, anchor = cur, cur
+			next = anchor.firstElementChild()
+
+		case rx.OpEnter:
+			if anchor, err = elem(next); err != nil {
+				return err
+			}
+			next = anchor.firstElementChild()
+
+		case rx.OpSkip:
+			n, err := strconv.Atoi(in.Args[0])
+			if err != nil {
+				return fail("invalid count: %s", err)
+			}
+			for range n {
+				if next == nil {
+					return fail("skipping past the last element")
+				}
+				next = next.nextElementSibling()
+			}
+
+		case rx.OpMove:
+			n := byID(in.Args[0])
+			if n == nil {
+				return fail("no element with id %s", in.Args[0])
+			}
+			anchor.insertBefore(n, next)
+			next = n
+
+		case rx.OpRemove:
+			n, err := elem(next)
+			if err != nil {
+				return err
+			}
+			next = n.nextElementSibling()
+			n.remove()
+
+		case rx.OpCreateElement:
+			n := &Node{Type: ElementNode, TagName: in.Args[0]}
+			anchor.insertBefore(n, next)
+			next, anchor = nil, n
+
+		case rx.OpReuse:
+			n := byID(in.Args[0])
+			switch {
+			case n == nil:
+				return fail("couldn't reuse node of id %s, not found", in.Args[0])
+			case next != nil:
+				next.replaceWith(n)
+			default:
+				anchor.insertBefore(n, nil)
+			}
+			next = n.nextSibling()
+
+		case rx.OpReID:
+			n := byID(in.Args[0])
+			if n == nil {
+				return fail("no element with id %s", in.Args[0])
+			}
+			n.setAttr("id", in.Args[1])
+
+		case rx.OpSetClass:
+			anchor.setAttr("class", in.Args[0])
+		case rx.OpSetID:
+			anchor.setAttr("id", in.Args[0])
+		case rx.OpSetAttr:
+			anchor.setAttr(in.Args[0], in.Args[1])
+		case rx.OpRemoveAttr:
+			anchor.removeAttr(in.Args[0])
+		case rx.OpSetProp:
+			if !json.Valid([]byte(in.Args[1])) {
+				return fail("invalid value for property %s", in.Args[0])
+			}
+			anchor.setProp(in.Args[0], in.Args[1])
+		case rx.OpSetStyle:
+			anchor.setStyle(in.Args[0], in.Args[1])
+
+		case rx.OpAddText:
+			t := &Node{Type: TextNode, Data: in.Args[0]}
+			if next != nil {
+				next.replaceWith(t)
+			} else {
+				anchor.insertBefore(t, nil)
+			}
+
+		case rx.OpSetText:
+			t := anchor.firstChild()
+			switch {
+			case t != nil && t.Type == TextNode && in.Args[0] == "":
+				t.remove()
+			case t != nil && t.Type == TextNode:
+				t.Data = in.Args[0]
+			case in.Args[0] != "":
+				anchor.insertBefore(&Node{Type: TextNode, Data: in.Args[0]}, t)
+			}
+
+		case rx.OpNext:
+			if len(outside) > 0 && anchor == outside[len(outside)-1][2] {
+				p := anchor
+				anchor, next = outside[len(outside)-1][0], outside[len(outside)-1][1]
+				outside = outside[:len(outside)-1]
+				if root != ndoc && p.firstElementChild() == nil {
+					// the last portal of the target was closed
+					if p.Type == FragmentNode {
+						for id, h := range d.Hosts {
+							if h == p {
+								delete(d.Hosts, id)
+							}
+						}
+					} else {
+						p.remove()
+					}
+				}
+				continue
+			}
+			if anchor.Parent == nil {
+				return fail("leaving the root")
+			}
+			next, anchor = anchor.nextSibling(), anchor.Parent
+		}
+	}
+
+	return ErrNoTerm
+}
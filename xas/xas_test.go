@@ -0,0 +1,293 @@
+//go:build !js
+
+package xas_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/TroutSoftware/rx"
+	"github.com/TroutSoftware/rx/xas"
+)
+
+type items []string
+
+func TestGenerations(t *testing.T) {
+	var add, drop rx.Entity
+	ng := rx.New(rx.WidgetFunc(func(ctx rx.Context) *rx.Node {
+		list := rx.Get(`<ul class="list">`)
+		for _, it := range rx.ValueOf[items](ctx) {
+			list.AddChildren(rx.Get(`<li>`).SetText(it))
+		}
+		a := rx.Get(`<button>add</button>`).OnIntent(rx.Click, func(ctx rx.Context) rx.Context {
+			v := rx.ValueOf[items](ctx)
+			return rx.WithValue(ctx, append(v[:len(v):len(v)], strings.Repeat("x", len(v)+1)))
+		})
+		d := rx.Get(`<button>drop</button>`).OnIntent(rx.Click, func(ctx rx.Context) rx.Context {
+			return rx.WithValue(ctx, rx.ValueOf[items](ctx)[1:])
+		})
+		add, drop = a.GiveKey(ctx).Entity, d.GiveKey(ctx).Entity
+		return rx.Get(`<div>`).AddChildren(list, a, d)
+	}))
+
+	doc := xas.NewDocument()
+	dispatch := func(cf rx.CallFrame) {
+		t.Helper()
+		prog, _, err := ng.Dispatch(cf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := doc.Apply(prog); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dispatch(rx.CallFrame{})
+	list := doc.Root.Children[0].Children[0]
+	dispatch(rx.CallFrame{IntentType: rx.Click, Entity: add})
+	dispatch(rx.CallFrame{IntentType: rx.Click, Entity: add})
+	dispatch(rx.CallFrame{IntentType: rx.Click, Entity: add})
+	dispatch(rx.CallFrame{IntentType: rx.Click, Entity: drop})
+
+	want := `<div><ul class="list"><li>xx</li><li>xxx</li></ul>` +
+		`<button id="2">add</button><button id="4">drop</button></div>`
+	if got := doc.Root.HTML(); got != want {
+		t.Errorf("after 5 generations:\n got %s\nwant %s", got, want)
+	}
+	if doc.Root.Children[0].Children[0] != list {
+		t.Errorf("list element was recreated instead of patched")
+	}
+	if doc.Gen != 5 {
+		t.Errorf("want 5 generations, got %d", doc.Gen)
+	}
+}
+
+func TestReuse(t *testing.T) {
+	type first struct{}
+	type second struct{}
+	type swap bool
+
+	keep := func(ctx rx.Context, n *rx.Node, key func(rx.Context) *rx.Node, kp func(rx.Context, *rx.Node)) *rx.Node {
+		if r := key(ctx); r != nil {
+			return r
+		}
+		kp(ctx, n)
+		return n
+	}
+
+	var btn rx.Entity
+	ng := rx.New(rx.WidgetFunc(func(ctx rx.Context) *rx.Node {
+		a := keep(ctx, rx.Get(`<li>a</li>`), rx.Reuse[first], rx.Keep[first])
+		b := keep(ctx, rx.Get(`<li>b</li>`), rx.Reuse[second], rx.Keep[second])
+		if rx.ValueOf[swap](ctx) {
+			a, b = b, a
+		}
+		bt := rx.Get(`<button>`).OnIntent(rx.Click, rx.Toggle(swap(true)))
+		btn = bt.GiveKey(ctx).Entity
+		return rx.Get(`<ul>`).AddChildren(a, b, bt)
+	}))
+
+	doc := xas.NewDocument()
+	for _, cf := range []rx.CallFrame{{}, {IntentType: rx.Click}} {
+		cf.Entity = btn
+		prog, _, err := ng.Dispatch(cf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := doc.Apply(prog); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ul := doc.Root.Children[0]
+	if got := ul.Children[0].TextContent() + ul.Children[1].TextContent(); got != "ba" {
+		t.Fatalf("elements not swapped: %s", doc.Root.HTML())
+	}
+	for _, li := range ul.Children[:2] {
+		if doc.Root.ElementByID(li.ID()) != li {
+			t.Errorf("duplicate id %s", li.ID())
+		}
+		if id := li.ID(); id != "3" && id != "5" {
+			t.Errorf("reused element not renamed: %s", doc.Root.HTML())
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	prog := rx.XAS(nil).AddInstr(rx.OpCreateElement, "div").AddInstr(rx.OpSetAttr, "title", "hi").AddInstr(rx.OpNext)
+	code, err := xas.Decode(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != 3 || code[1].String() != `SetAttr "title" "hi"` {
+		t.Errorf("invalid decoding: %v", code)
+	}
+
+	if err := xas.NewDocument().Apply(prog); err != xas.ErrNoTerm {
+		t.Errorf("missing term: got %v", err)
+	}
+	if _, err := xas.Decode(prog[:5]); err == nil {
+		t.Errorf("truncated program decoded")
+	}
+}
+
+func TestPortal(t *testing.T) {
+	type open bool
+	type count int
+
+	var btn, incr, dismiss rx.Entity
+	ng := rx.New(rx.WidgetFunc(func(ctx rx.Context) *rx.Node {
+		b := rx.Get(`<button>open</button>`).GiveKey(ctx).OnIntent(rx.Click, rx.Set(open(true)))
+		btn = b.Entity
+		menu := rx.Get(`<div class="menu">`).OnIntent(rx.Click, rx.Set(open(false))).AddChildren(b)
+		if rx.ValueOf[open](ctx) {
+			n := rx.ValueOf[count](ctx)
+			i := rx.Get(`<button>+</button>`).GiveKey(ctx).OnIntent(rx.Click, rx.Set(n+1))
+			c := rx.Get(`<button>close</button>`).GiveKey(ctx) // handled by the menu
+			incr, dismiss = i.Entity, c.Entity
+			menu.AddChildren(
+				rx.Portal("overlay", rx.Get(`<div class="modal">`).AddChildren(i, c)),
+				rx.Portal("#toasts", rx.Get(`<p>`).SetText(strings.Repeat("!", int(n)))),
+			)
+		}
+		return menu
+	}))
+
+	doc := xas.NewDocument()
+	dispatch := func(cf rx.CallFrame) {
+		t.Helper()
+		prog, _, err := ng.Dispatch(cf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := doc.Apply(prog); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dispatch(rx.CallFrame{})
+	dispatch(rx.CallFrame{IntentType: rx.Click, Entity: btn})
+	modal := doc.Root.Children[1].Children[0]
+	dispatch(rx.CallFrame{IntentType: rx.Click, Entity: incr})
+
+	want := `<div class="menu" id="8"><button id="2">open</button></div>` +
+		`<div data-portal="overlay"><div class="modal" id="10"><button id="4">+</button><button id="6">close</button></div></div>`
+	if got := doc.Root.HTML(); got != want {
+		t.Errorf("open portals:\n got %s\nwant %s", got, want)
+	}
+	if got := doc.Hosts["toasts"].HTML(); got != `<p id="12">!</p>` {
+		t.Errorf("host mount point: got %s", got)
+	}
+	if doc.Root.Children[1].Children[0] != modal {
+		t.Errorf("portal was recreated instead of patched")
+	}
+
+	dispatch(rx.CallFrame{IntentType: rx.Click, Entity: dismiss})
+	if got := doc.Root.HTML(); got != `<div class="menu" id="5"><button id="3">open</button></div>` {
+		t.Errorf("intent in portal must reach the parents of the portal, got %s", got)
+	}
+
+	// elements at the top of a portal are given an entity, the walk up the DOM stops at the mount point
+	dispatch(rx.CallFrame{IntentType: rx.Click, Entity: btn})
+	id, _ := strconv.Atoi(doc.Root.Children[1].Children[0].ID())
+	dispatch(rx.CallFrame{IntentType: rx.Click, Entity: rx.Entity(id)})
+	if len(doc.Root.Children) != 1 {
+		t.Errorf("intent on the modal must reach the parents of the portal, got %s", doc.Root.HTML())
+	}
+	if len(doc.Hosts) != 0 {
+		t.Errorf("host mount point not cleared: %v", doc.Hosts)
+	}
+}
+
+func TestPortalPatch(t *testing.T) {
+	type toasts int
+
+	var add, clear rx.Entity
+	ng := rx.New(rx.WidgetFunc(func(ctx rx.Context) *rx.Node {
+		a := rx.Get(`<button>`).GiveKey(ctx).OnIntent(rx.Click, func(ctx rx.Context) rx.Context {
+			return rx.WithValue(ctx, rx.ValueOf[toasts](ctx)+1)
+		})
+		c := rx.Get(`<button>`).GiveKey(ctx).OnIntent(rx.Click, rx.Set(toasts(0)))
+		add, clear = a.Entity, c.Entity
+		main := rx.Get(`<main>`).AddChildren(a, c)
+		for i := range int(rx.ValueOf[toasts](ctx)) {
+			main.AddChildren(rx.Portal("overlay", rx.Get(`<p>`).SetText(strconv.Itoa(i))))
+		}
+		return main
+	}))
+	defer ng.Close()
+
+	doc := xas.NewDocument()
+	var main *xas.Node
+	dispatch := func(cf rx.CallFrame) {
+		t.Helper()
+		prog, _, err := ng.Dispatch(cf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if main != nil && prog[0] != rx.OpPatch {
+			t.Errorf("%s: the view must be patched when the portals change", cf.IntentType)
+		}
+		if err := doc.Apply(prog); err != nil {
+			t.Fatal(err)
+		}
+		main = doc.Root.Children[0]
+	}
+
+	dispatch(rx.CallFrame{})
+	first := main
+	dispatch(rx.CallFrame{IntentType: rx.Click, Entity: add})
+	dispatch(rx.CallFrame{IntentType: rx.Click, Entity: add})
+	if main != first {
+		t.Errorf("view rebuilt when opening a portal")
+	}
+	if got, want := doc.Root.HTML(), `<main><button id="2"></button><button id="4"></button></main>`+
+		`<div data-portal="overlay"><p>0</p><p>1</p></div>`; got != want {
+		t.Errorf("two portals on a target:\n got %s\nwant %s", got, want)
+	}
+
+	dispatch(rx.CallFrame{IntentType: rx.Click, Entity: clear})
+	if got, want := doc.Root.HTML(), `<main><button id="3"></button><button id="5"></button></main>`; got != want {
+		t.Errorf("closed portals must remove their target:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestStyle(t *testing.T) {
+	type plain bool
+
+	var btn rx.Entity
+	ng := rx.New(rx.WidgetFunc(func(ctx rx.Context) *rx.Node {
+		color := "red !IMPORTANT"
+		if rx.ValueOf[plain](ctx) {
+			color = "blue"
+		}
+		b := rx.Get(`<button>`).GiveKey(ctx).OnIntent(rx.Click, rx.Set(plain(true)))
+		btn = b.Entity
+		return b.SetStyle("color", color).SetStyle("width", "2px")
+	}))
+	defer ng.Close()
+
+	doc := xas.NewDocument()
+	for _, it := range []rx.IntentType{rx.NoIntent, rx.Click} {
+		prog, _, err := ng.Dispatch(rx.CallFrame{IntentType: it, Entity: btn})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := doc.Apply(prog); err != nil {
+			t.Fatal(err)
+		}
+
+		b := doc.Root.Children[0]
+		want, priority := "red", "important"
+		if it == rx.Click {
+			want, priority = "blue", ""
+		}
+		if got := b.Style("color"); got != want || b.StylePriority("color") != priority {
+			t.Errorf("%s: color %q %q, want %q %q", it, got, b.StylePriority("color"), want, priority)
+		}
+		if b.StylePriority("width") != "" {
+			t.Errorf("%s: width has priority %q", it, b.StylePriority("width"))
+		}
+	}
+}